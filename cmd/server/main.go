@@ -7,11 +7,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"mock-harbor/internal/config"
 	"mock-harbor/internal/hotreload"
 	"mock-harbor/internal/server"
+	"mock-harbor/internal/templating"
 	"mock-harbor/internal/validation"
 )
 
@@ -60,6 +62,7 @@ func main() {
 	configDir := flag.String("config-dir", "configs", "Directory containing configuration files")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
 	disableHotReload := flag.Bool("no-hot-reload", false, "Disable hot reloading of configuration files")
+	flag.IntVar(&templating.MaxRepeat, "max-repeat", templating.MaxRepeat, "Maximum number of items a template may repeat")
 	flag.Parse()
 
 	// Resolve absolute path to config directory
@@ -129,7 +132,7 @@ func main() {
 		
 		// Validate mock configurations
 		mockConfigPath := filepath.Join(absConfigDir, svcRef.Name, "usecases", svcRef.Usecase, "all.json")
-		validationResult = validation.ValidateMockConfigs(mocks, mockConfigPath)
+		validationResult = validation.ValidateMockConfigs(mocks, mockConfigPath, config.HasUsecaseFallback(absConfigDir, svcRef.Name, svcRef.Usecase))
 		if !validationResult.IsValid() {
 			log.Printf("Mock configurations for '%s/%s' validation errors:", svcRef.Name, svcRef.Usecase)
 			for _, err := range validationResult.Errors {
@@ -138,9 +141,19 @@ func main() {
 			log.Printf("Skipping service '%s' due to mock configuration errors.", svcRef.Name)
 			continue
 		}
+		for _, warning := range validationResult.Warnings {
+			log.Printf("Warning: %s", warning.Error())
+		}
 
-		// Create and add server
+		// Create server and configure responses for unmatched requests
 		mockServer := server.NewMockServer(svcRef.Name, svcCfg.Port, mocks, svcCfg)
+		if err := mockServer.LoadFallbacks(absConfigDir, svcRef.Usecase, svcCfg); err != nil {
+			log.Printf("Error loading fallback for %s/%s: %v", svcRef.Name, svcRef.Usecase, err)
+			log.Printf("Skipping service '%s' due to fallback configuration errors.", svcRef.Name)
+			continue
+		}
+
+		// Add server
 		manager.AddServer(mockServer)
 	}
 
@@ -152,6 +165,10 @@ func main() {
 	// Print server information
 	log.Printf("Starting %d mock servers:", len(manager.Servers))
 	for _, srv := range manager.Servers {
+		if len(srv.Hosts) > 0 {
+			log.Printf("  - %s on port %d for %s", srv.ServiceName, srv.Port, strings.Join(srv.Hosts, ", "))
+			continue
+		}
 		log.Printf("  - %s on port %d", srv.ServiceName, srv.Port)
 	}
 
@@ -1,12 +1,18 @@
 package config
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
 
 	"gopkg.in/yaml.v3"
+
+	"mock-harbor/internal/templating"
 )
 
 // GlobalConfig represents the root configuration
@@ -24,7 +30,58 @@ type ServiceReference struct {
 type ServiceConfig struct {
 	Port int    `yaml:"port"`
 	Name string `yaml:"name"`
-	Delay DelayConfig `yaml:"delay,omitempty"`
+	// Hosts lets services share a port, each answering requests for its own host names
+	Hosts       []string          `yaml:"hosts,omitempty"`
+	Delay       DelayConfig       `yaml:"delay,omitempty"`
+	Diagnostics DiagnosticsConfig `yaml:"diagnostics,omitempty"`
+	Fallback    *FallbackConfig   `yaml:"fallback,omitempty"`
+	// RandomSeed seeds the random delays and response picks, for reproducible runs
+	RandomSeed *int64 `yaml:"randomSeed,omitempty"`
+	// Compression compresses response bodies in an encoding the request accepts
+	Compression *CompressionConfig `yaml:"compression,omitempty"`
+}
+
+// CompressionConfig controls how response bodies are compressed
+type CompressionConfig struct {
+	// Whether to compress response bodies
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Encodings applied in order of preference when the request accepts several, gzip and deflate by default
+	Encodings []string `yaml:"encodings,omitempty" json:"encodings,omitempty"`
+	// Bodies smaller than this many bytes are sent uncompressed
+	MinSize int `yaml:"minSize,omitempty" json:"minSize,omitempty"`
+	// WrongEncoding is sent as the Content-Encoding in place of the encoding actually used,
+	// to test how clients handle mislabeled bodies
+	WrongEncoding string `yaml:"wrongEncoding,omitempty" json:"wrongEncoding,omitempty"`
+}
+
+// Encodings that bodies can be compressed with
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// SupportedEncodings returns the encodings to compress bodies with, in order of preference
+func (c *CompressionConfig) SupportedEncodings() []string {
+	if len(c.Encodings) == 0 {
+		return []string{EncodingGzip, EncodingDeflate}
+	}
+	return c.Encodings
+}
+
+// FallbackConfig describes how requests that match no mock are answered
+type FallbackConfig struct {
+	// Usecase of the same service whose mocks are tried next, for layered usecases
+	Usecase string `yaml:"usecase,omitempty" json:"usecase,omitempty"`
+	// Response returned when neither this usecase nor the one it falls through to matches
+	Response *ResponseConfig `yaml:"response,omitempty" json:"response,omitempty"`
+}
+
+// DiagnosticsConfig controls the near-miss report returned for unmatched requests
+type DiagnosticsConfig struct {
+	// Whether to return a plain 404 instead of the near-miss report
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Number of closest mocks to report, defaults to 3
+	Limit int `yaml:"limit,omitempty"`
 }
 
 // DelayConfig represents configuration for simulating response latency
@@ -41,22 +98,372 @@ type DelayConfig struct {
 
 // RequestConfig represents the request matching criteria
 type RequestConfig struct {
-	Path   string                 `json:"path"`
-	Method string                 `json:"method"`
-	Body   map[string]interface{} `json:"body,omitempty"`
+	// Path may contain parameters like /api/users/{id} or a trailing catch-all like /files/{path...}
+	Path string `json:"path,omitempty"`
+	// PathRegex is matched against the whole request path, as an alternative to Path
+	PathRegex string `json:"pathRegex,omitempty"`
+	// Method may be left empty inside combinators, or when combinators constrain it
+	Method string `json:"method,omitempty"`
+	// Body may be any JSON value; objects match when the request contains the given fields
+	Body interface{} `json:"body,omitempty"`
+	// ArrayMatch controls how arrays in Body are compared: exact (default), unordered or subset
+	ArrayMatch string `json:"arrayMatch,omitempty"`
+	// Query matches query string parameters by name
+	Query map[string]ValueMatcher `json:"query,omitempty"`
+	// Headers matches request headers by name, case-insensitively
+	Headers map[string]ValueMatcher `json:"headers,omitempty"`
+	// Cookies matches request cookies by name
+	Cookies map[string]ValueMatcher `json:"cookies,omitempty"`
+	// BodyMatchers checks JSONPath expressions against the request body, alongside Body
+	BodyMatchers []BodyMatcher `json:"bodyMatchers,omitempty"`
+	// BodyEquals requires the raw request body to equal the given text
+	BodyEquals *string `json:"bodyEquals,omitempty"`
+	// BodyContains requires the raw request body to contain the given text
+	BodyContains string `json:"bodyContains,omitempty"`
+	// BodyRegex requires the raw request body to match the regular expression
+	BodyRegex string `json:"bodyRegex,omitempty"`
+	// XPath checks expressions against an XML request body
+	XPath []XPathMatcher `json:"xpath,omitempty"`
+	// Namespaces maps the prefixes used in XPath expressions to namespace URIs
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+	// Form matches fields of urlencoded and multipart form bodies by name
+	Form map[string]ValueMatcher `json:"form,omitempty"`
+	// Multipart matches parts of multipart/form-data bodies
+	Multipart []PartMatcher `json:"multipart,omitempty"`
+	// AnyOf requires at least one of the nested criteria to match
+	AnyOf []RequestConfig `json:"anyOf,omitempty"`
+	// AllOf requires every one of the nested criteria to match
+	AllOf []RequestConfig `json:"allOf,omitempty"`
+	// Not requires the nested criteria not to match
+	Not *RequestConfig `json:"not,omitempty"`
+
+	// PathSegments holds the parsed path template, populated at load time
+	PathSegments []PathSegment `json:"-"`
+	// PathPattern holds the compiled PathRegex, populated at load time
+	PathPattern *regexp.Regexp `json:"-"`
+	// BodyPattern holds the compiled BodyRegex, populated at load time
+	BodyPattern *regexp.Regexp `json:"-"`
+}
+
+// compile prepares the request matching criteria once at load time.
+// Invalid templates and patterns are left uncompiled and reported by validation.
+func (r *RequestConfig) compile() {
+	if IsPathTemplate(r.Path) {
+		if segments, err := ParsePathTemplate(r.Path); err == nil {
+			r.PathSegments = segments
+		}
+	}
+	if r.PathRegex != "" {
+		if pattern, err := CompilePathRegex(r.PathRegex); err == nil {
+			r.PathPattern = pattern
+		}
+	}
+	if r.BodyRegex != "" {
+		if pattern, err := regexp.Compile(r.BodyRegex); err == nil {
+			r.BodyPattern = pattern
+		}
+	}
+	compileValueMatchers(r.Query)
+	compileValueMatchers(r.Headers)
+	compileValueMatchers(r.Cookies)
+	for i := range r.BodyMatchers {
+		r.BodyMatchers[i].compile()
+	}
+	for i := range r.XPath {
+		r.XPath[i].compile(r.Namespaces)
+	}
+	compileValueMatchers(r.Form)
+	for i := range r.Multipart {
+		r.Multipart[i].compile()
+	}
+	for i := range r.AnyOf {
+		r.AnyOf[i].compile()
+	}
+	for i := range r.AllOf {
+		r.AllOf[i].compile()
+	}
+	if r.Not != nil {
+		r.Not.compile()
+	}
+}
+
+// HasCombinators reports whether the request nests criteria in anyOf, allOf or not
+func (r RequestConfig) HasCombinators() bool {
+	return len(r.AnyOf) > 0 || len(r.AllOf) > 0 || r.Not != nil
 }
 
 // ResponseConfig represents the mocked response
 type ResponseConfig struct {
-	Body       map[string]interface{} `json:"body"`
-	StatusCode int                    `json:"statusCode"`
-	Headers    map[string]string      `json:"headers"`
+	// Body may be any JSON value: an object, an array or a scalar
+	Body interface{} `json:"body" yaml:"body"`
+	// BodyXML is a raw XML document returned instead of a JSON body
+	BodyXML string `json:"bodyXml,omitempty" yaml:"bodyXml,omitempty"`
+	// BodyText is returned as is, for plain text, HTML, CSV and other text formats
+	BodyText string `json:"bodyText,omitempty" yaml:"bodyText,omitempty"`
+	// BodyBase64 holds binary content such as images or protobuf messages, base64-encoded
+	BodyBase64 string `json:"bodyBase64,omitempty" yaml:"bodyBase64,omitempty"`
+	// BodyFile is a file returned as the body, relative to the usecase directory.
+	// Its name may be a template such as products/{{.Params.id}}.json.
+	BodyFile   string            `json:"bodyFile,omitempty" yaml:"bodyFile,omitempty"`
+	StatusCode int               `json:"statusCode" yaml:"statusCode"`
+	Headers    map[string]string `json:"headers" yaml:"headers"`
+
+	// Seed is a template rendering the key that seeds fake data, for reproducible responses
+	Seed string `json:"seed,omitempty" yaml:"seed,omitempty"`
+	// Stream writes the body over time as chunks or Server-Sent Events, in place of a body
+	Stream *StreamConfig `json:"stream,omitempty" yaml:"stream,omitempty"`
+	// Representations are alternative bodies keyed by media type, one of which is picked
+	// according to the Accept header in place of a body
+	Representations map[string]ResponseConfig `json:"representations,omitempty" yaml:"representations,omitempty"`
+	// Weight is the relative chance of picking the response in random mode, 1 if unset
+	Weight int `json:"weight,omitempty" yaml:"weight,omitempty"`
+
+	// BodyTemplate holds Body with templated strings parsed, populated at load time
+	BodyTemplate interface{} `json:"-" yaml:"-"`
+	// BodyXMLTemplate holds the parsed BodyXML if it is templated, populated at load time
+	BodyXMLTemplate *template.Template `json:"-" yaml:"-"`
+	// BodyTextTemplate holds the parsed BodyText if it is templated, populated at load time
+	BodyTextTemplate *template.Template `json:"-" yaml:"-"`
+	// BodyBytes holds the decoded BodyBase64, populated at load time
+	BodyBytes []byte `json:"-" yaml:"-"`
+	// BodyFilePath is the resolved BodyFile, populated at load time if its name isn't templated
+	BodyFilePath string `json:"-" yaml:"-"`
+	// BodyFileContent holds the content of BodyFilePath, populated at load time
+	BodyFileContent []byte `json:"-" yaml:"-"`
+	// BodyFileTemplate holds the parsed BodyFile if its name is templated, populated at load time
+	BodyFileTemplate *template.Template `json:"-" yaml:"-"`
+	// BodyFileDir is the usecase directory BodyFile is resolved against, populated at load time
+	BodyFileDir string `json:"-" yaml:"-"`
+	// HeaderTemplates holds the parsed templated header values, populated at load time
+	HeaderTemplates map[string]*template.Template `json:"-" yaml:"-"`
+	// SeedTemplate holds the parsed Seed, populated at load time
+	SeedTemplate *template.Template `json:"-" yaml:"-"`
+}
+
+// compile parses the templates in the response once at load time.
+// Invalid templates are left unparsed and reported by validation.
+func (r *ResponseConfig) compile() {
+	r.BodyTemplate = nil
+	if r.Body != nil {
+		if body, err := CompileTemplateValue("body", r.Body); err == nil {
+			r.BodyTemplate = body
+		}
+	}
+
+	r.BodyXMLTemplate = nil
+	if templating.IsTemplate(r.BodyXML) {
+		if tmpl, err := templating.Parse("bodyXml", r.BodyXML); err == nil {
+			r.BodyXMLTemplate = tmpl
+		}
+	}
+
+	r.BodyTextTemplate = nil
+	if templating.IsTemplate(r.BodyText) {
+		if tmpl, err := templating.Parse("bodyText", r.BodyText); err == nil {
+			r.BodyTextTemplate = tmpl
+		}
+	}
+
+	r.BodyBytes = nil
+	if r.BodyBase64 != "" {
+		if content, err := base64.StdEncoding.DecodeString(r.BodyBase64); err == nil {
+			r.BodyBytes = content
+		}
+	}
+
+	if r.Stream != nil {
+		r.Stream.compile()
+	}
+	for mediaType, representation := range r.Representations {
+		representation.compile()
+		r.Representations[mediaType] = representation
+	}
+
+	r.SeedTemplate = nil
+	if r.Seed != "" {
+		if tmpl, err := templating.Parse("seed", r.Seed); err == nil {
+			r.SeedTemplate = tmpl
+		}
+	}
+
+	r.HeaderTemplates = nil
+	for name, value := range r.Headers {
+		if !templating.IsTemplate(value) {
+			continue
+		}
+		if tmpl, err := templating.Parse("headers."+name, value); err == nil {
+			if r.HeaderTemplates == nil {
+				r.HeaderTemplates = make(map[string]*template.Template)
+			}
+			r.HeaderTemplates[name] = tmpl
+		}
+	}
+}
+
+// Keys of the directive that renders an array by repeating an item
+const (
+	RepeatKey     = "$repeat"
+	RepeatItemKey = "$item"
+)
+
+// TemplateRepeat is a compiled $repeat directive, which renders Item Count times into an array
+type TemplateRepeat struct {
+	// Count is the number of items, an int or a template rendering one
+	Count interface{}
+	// Item is the compiled value rendered for each item
+	Item interface{}
+}
+
+// compileRepeat compiles a {"$repeat": count, "$item": value} directive
+func compileRepeat(name string, directive map[string]interface{}) (*TemplateRepeat, error) {
+	item, exists := directive[RepeatItemKey]
+	if !exists || len(directive) != 2 {
+		return nil, fmt.Errorf("%s: %s must be used with %s and no other keys", name, RepeatKey, RepeatItemKey)
+	}
+
+	repeat := &TemplateRepeat{}
+	switch count := directive[RepeatKey].(type) {
+	case float64:
+		if count < 0 || count != float64(int(count)) {
+			return nil, fmt.Errorf("%s: %s must be a non-negative integer", name, RepeatKey)
+		}
+		if err := templating.CheckRepeat(int(count)); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		repeat.Count = int(count)
+	case int:
+		if count < 0 {
+			return nil, fmt.Errorf("%s: %s must be a non-negative integer", name, RepeatKey)
+		}
+		if err := templating.CheckRepeat(count); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		repeat.Count = count
+	case string:
+		tmpl, err := templating.Parse(name+"."+RepeatKey, count)
+		if err != nil {
+			return nil, err
+		}
+		repeat.Count = tmpl
+	default:
+		return nil, fmt.Errorf("%s: %s must be a number or a template", name, RepeatKey)
+	}
+
+	compiled, err := CompileTemplateValue(name+"[]", item)
+	if err != nil {
+		return nil, err
+	}
+	repeat.Item = compiled
+	return repeat, nil
+}
+
+// resolveBodyFile resolves the body file against the usecase directory and, unless
+// its name is templated, loads its content. Missing files are reported by validation.
+func (r *ResponseConfig) resolveBodyFile(usecaseDir string) {
+	for mediaType, representation := range r.Representations {
+		representation.resolveBodyFile(usecaseDir)
+		r.Representations[mediaType] = representation
+	}
+
+	r.BodyFileDir, r.BodyFilePath, r.BodyFileContent, r.BodyFileTemplate = "", "", nil, nil
+	if r.BodyFile == "" {
+		return
+	}
+
+	r.BodyFileDir = usecaseDir
+	if templating.IsTemplate(r.BodyFile) {
+		if tmpl, err := templating.Parse("bodyFile", r.BodyFile); err == nil {
+			r.BodyFileTemplate = tmpl
+		}
+		return
+	}
+
+	// Files outside the usecase directory are reported by validation and never read
+	path, inside := JoinBodyFile(usecaseDir, r.BodyFile)
+	r.BodyFilePath = path
+	if !inside {
+		return
+	}
+	if content, err := os.ReadFile(path); err == nil {
+		r.BodyFileContent = content
+	}
+}
+
+// JoinBodyFile resolves a body file name against the usecase directory, reporting
+// whether the resulting path stays inside it
+func JoinBodyFile(usecaseDir, name string) (string, bool) {
+	path := filepath.Join(usecaseDir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(usecaseDir, path)
+	return path, err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CompileTemplateValue walks a decoded JSON value and parses the strings containing
+// template actions, returning a copy in which they are replaced by their templates.
+// Strings that are a single action render to the JSON value they output, if any.
+func CompileTemplateValue(name string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !templating.IsTemplate(v) {
+			return v, nil
+		}
+		return templating.Parse(name, v)
+	case map[string]interface{}:
+		if _, exists := v[RepeatKey]; exists {
+			return compileRepeat(name, v)
+		}
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			compiled, err := CompileTemplateValue(name+"."+key, item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = compiled
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			compiled, err := CompileTemplateValue(fmt.Sprintf("%s[%d]", name, i), item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = compiled
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
 }
 
 // MockConfig represents a request/response pair
 type MockConfig struct {
 	Request  RequestConfig  `json:"request"`
 	Response ResponseConfig `json:"response"`
+	// Responses returns a different response on successive calls, in place of Response
+	Responses []ResponseConfig `json:"responses,omitempty"`
+	// Mode selects how Responses are returned, sequential-stop-at-last by default
+	Mode string `json:"mode,omitempty"`
+	// Compression overrides the service's compression settings for the mock's responses
+	Compression *CompressionConfig `json:"compression,omitempty"`
+	// WebSocket upgrades matching requests to a scripted WebSocket conversation, in place of a response
+	WebSocket *WebSocketConfig `json:"websocket,omitempty"`
+	// Priority ranks the mock ahead of less specific mocks matching the same request, higher wins
+	Priority int `json:"priority,omitempty"`
+}
+
+// Response sequence modes
+const (
+	// ModeSequential returns the responses in order, repeating the last one once exhausted
+	ModeSequential = "sequential-stop-at-last"
+	// ModeCycle returns the responses in order, starting over once exhausted
+	ModeCycle = "cycle"
+	// ModeRandom returns a response picked at random on each call, according to the response weights
+	ModeRandom = "random"
+)
+
+// HasResponses reports whether the mock returns a sequence of responses
+func (m MockConfig) HasResponses() bool {
+	return len(m.Responses) > 0
 }
 
 // ConfigError represents an error with additional context about the configuration file
@@ -155,8 +562,14 @@ func LoadServiceConfig(basePath, serviceName string) (*ServiceConfig, error) {
 func LoadMockConfigs(basePath, serviceName, usecase string) ([]MockConfig, error) {
 	configPath := filepath.Join(basePath, serviceName, "usecases", usecase, "all.json")
 	
+	// A usecase that only declares a fallback doesn't need any mocks
+	hasFallback := HasUsecaseFallback(basePath, serviceName, usecase)
+
 	// Check if file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if hasFallback {
+			return []MockConfig{}, nil
+		}
 		return nil, &ConfigError{
 			FilePath: configPath,
 			Message:  fmt.Sprintf("mock configurations for '%s/%s' not found", serviceName, usecase),
@@ -182,12 +595,76 @@ func LoadMockConfigs(basePath, serviceName, usecase string) ([]MockConfig, error
 		}
 	}
 
-	if len(configs) == 0 {
+	if len(configs) == 0 && !hasFallback {
 		return nil, &ConfigError{
 			FilePath: configPath,
 			Message:  fmt.Sprintf("no mock configurations found in '%s/%s'", serviceName, usecase),
 		}
 	}
 
+	for i := range configs {
+		configs[i].Request.compile()
+		configs[i].Response.compile()
+		configs[i].Response.resolveBodyFile(filepath.Dir(configPath))
+		for j := range configs[i].Responses {
+			configs[i].Responses[j].compile()
+			configs[i].Responses[j].resolveBodyFile(filepath.Dir(configPath))
+		}
+		if configs[i].WebSocket != nil {
+			configs[i].WebSocket.compile()
+		}
+	}
+
 	return configs, nil
 }
+
+// HasUsecaseFallback reports whether the usecase directory contains a fallback.json file
+func HasUsecaseFallback(basePath, serviceName, usecase string) bool {
+	_, err := os.Stat(filepath.Join(basePath, serviceName, "usecases", usecase, "fallback.json"))
+	return err == nil
+}
+
+// LoadFallbackConfig loads the fallback for unmatched requests of a usecase. A fallback.json
+// file in the usecase directory takes precedence over the service's fallback setting.
+// It returns nil if neither is configured.
+func LoadFallbackConfig(basePath, serviceName, usecase string, serviceConfig *ServiceConfig) (*FallbackConfig, error) {
+	configPath := filepath.Join(basePath, serviceName, "usecases", usecase, "fallback.json")
+
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		if serviceConfig != nil && serviceConfig.Fallback != nil {
+			// Copy the service's fallback, its body file is resolved against each usecase
+			fallback := *serviceConfig.Fallback
+			if fallback.Response != nil {
+				response := *fallback.Response
+				response.compile()
+				response.resolveBodyFile(filepath.Dir(configPath))
+				fallback.Response = &response
+			}
+			return &fallback, nil
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ConfigError{
+			FilePath: configPath,
+			Message:  fmt.Sprintf("error reading fallback config for '%s/%s'", serviceName, usecase),
+			Err:      err,
+		}
+	}
+
+	var fallback FallbackConfig
+	if err := json.Unmarshal(data, &fallback); err != nil {
+		return nil, &ConfigError{
+			FilePath: configPath,
+			Message:  fmt.Sprintf("error unmarshalling fallback config for '%s/%s', check JSON syntax", serviceName, usecase),
+			Err:      err,
+		}
+	}
+	if fallback.Response != nil {
+		fallback.Response.compile()
+		fallback.Response.resolveBodyFile(filepath.Dir(configPath))
+	}
+
+	return &fallback, nil
+}
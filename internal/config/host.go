@@ -0,0 +1,8 @@
+package config
+
+import "strings"
+
+// NormalizeHost prepares a host name for comparison, ignoring case and a trailing dot
+func NormalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
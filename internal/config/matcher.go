@@ -0,0 +1,182 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"mock-harbor/internal/jsonpath"
+	"mock-harbor/internal/xpath"
+)
+
+// ValueMatcher describes how a named request value, such as a query parameter, header or
+// cookie, must match. Several values may be sent under the same name; Equals, Contains and
+// Regex match if any of them match, while Values requires exactly the listed values in any order.
+//
+// In JSON a plain string is shorthand for equals and an array of strings for values.
+type ValueMatcher struct {
+	// Equals requires a value equal to the given string
+	Equals *string `json:"equals,omitempty"`
+	// Contains requires a value containing the given substring
+	Contains string `json:"contains,omitempty"`
+	// Regex requires a value matching the regular expression
+	Regex string `json:"regex,omitempty"`
+	// Present requires the value to be sent, with any content
+	Present bool `json:"present,omitempty"`
+	// Absent requires the value not to be sent at all
+	Absent bool `json:"absent,omitempty"`
+	// Values requires exactly these values, in any order
+	Values []string `json:"values,omitempty"`
+
+	// Pattern holds the compiled Regex, populated at load time
+	Pattern *regexp.Regexp `json:"-"`
+}
+
+// UnmarshalJSON accepts the string and array shorthands as well as the full object form
+func (m *ValueMatcher) UnmarshalJSON(data []byte) error {
+	var equals string
+	if err := json.Unmarshal(data, &equals); err == nil {
+		*m = ValueMatcher{Equals: &equals}
+		return nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(data, &values); err == nil {
+		*m = ValueMatcher{Values: values}
+		return nil
+	}
+
+	type plain ValueMatcher
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+// compile prepares the matcher's regex once at load time
+func (m *ValueMatcher) compile() {
+	if m.Regex != "" {
+		if pattern, err := regexp.Compile(m.Regex); err == nil {
+			m.Pattern = pattern
+		}
+	}
+}
+
+// compileValueMatchers compiles every matcher in a map of named matchers
+func compileValueMatchers(matchers map[string]ValueMatcher) {
+	for name, matcher := range matchers {
+		matcher.compile()
+		matchers[name] = matcher
+	}
+}
+
+// PartMatcher checks a single part of a multipart/form-data request body.
+// It matches when any part with the given name satisfies all of the set matchers.
+type PartMatcher struct {
+	// Name is the form field name of the part
+	Name string `json:"name"`
+	// Filename matches the part's file name
+	Filename *ValueMatcher `json:"filename,omitempty"`
+	// ContentType matches the part's Content-Type header
+	ContentType *ValueMatcher `json:"contentType,omitempty"`
+	// Content matches the part's content as text
+	Content *ValueMatcher `json:"content,omitempty"`
+}
+
+// compile prepares the part's matchers once at load time
+func (m *PartMatcher) compile() {
+	for _, matcher := range []*ValueMatcher{m.Filename, m.ContentType, m.Content} {
+		if matcher != nil {
+			matcher.compile()
+		}
+	}
+}
+
+// XPathMatcher checks values selected from an XML request body by an XPath expression,
+// using the same operators as BodyMatcher
+type XPathMatcher struct {
+	// Expr is the XPath expression, e.g. //soap:Body/m:GetOrder/m:OrderId
+	Expr string `json:"expr"`
+	// Op is the comparison operator, defaults to equals
+	Op string `json:"op,omitempty"`
+	// Value is the operand, compared with the text of the selected nodes
+	Value interface{} `json:"value,omitempty"`
+
+	// Selector holds the compiled Expr, populated at load time
+	Selector *xpath.Expr `json:"-"`
+	// Pattern holds the compiled regex operand, populated at load time
+	Pattern *regexp.Regexp `json:"-"`
+}
+
+// Operator returns the matcher's operator, applying the default
+func (m XPathMatcher) Operator() string {
+	if m.Op == "" {
+		return OpEquals
+	}
+	return m.Op
+}
+
+// compile prepares the matcher's expression and regex once at load time
+func (m *XPathMatcher) compile(namespaces map[string]string) {
+	if selector, err := xpath.Compile(m.Expr, namespaces); err == nil {
+		m.Selector = selector
+	}
+	if expr, ok := m.Value.(string); ok && m.Operator() == OpRegex {
+		if pattern, err := regexp.Compile(expr); err == nil {
+			m.Pattern = pattern
+		}
+	}
+}
+
+// Array match modes for request bodies
+const (
+	ArrayMatchExact     = "exact"
+	ArrayMatchUnordered = "unordered"
+	ArrayMatchSubset    = "subset"
+)
+
+// Body matcher operators
+const (
+	OpEquals    = "equals"
+	OpNotEquals = "notEquals"
+	OpContains  = "contains"
+	OpRegex     = "regex"
+	OpExists    = "exists"
+	OpAbsent    = "absent"
+	OpGt        = "gt"
+	OpLt        = "lt"
+	OpIn        = "in"
+)
+
+// BodyMatcher checks values selected from the JSON request body by a JSONPath expression.
+// When the expression selects several values the matcher passes if any of them satisfy
+// the operator, except for notEquals and absent which must hold for all of them.
+type BodyMatcher struct {
+	// Path is the JSONPath expression, e.g. $.items[0].qty
+	Path string `json:"path"`
+	// Op is the comparison operator, defaults to equals
+	Op string `json:"op,omitempty"`
+	// Value is the operand; a list of candidates for in, a pattern for regex
+	Value interface{} `json:"value,omitempty"`
+
+	// Selector holds the compiled Path, populated at load time
+	Selector *jsonpath.Path `json:"-"`
+	// Pattern holds the compiled regex operand, populated at load time
+	Pattern *regexp.Regexp `json:"-"`
+}
+
+// Operator returns the matcher's operator, applying the default
+func (m BodyMatcher) Operator() string {
+	if m.Op == "" {
+		return OpEquals
+	}
+	return m.Op
+}
+
+// compile prepares the matcher's path and regex once at load time
+func (m *BodyMatcher) compile() {
+	if selector, err := jsonpath.Parse(m.Path); err == nil {
+		m.Selector = &selector
+	}
+	if expr, ok := m.Value.(string); ok && m.Operator() == OpRegex {
+		if pattern, err := regexp.Compile(expr); err == nil {
+			m.Pattern = pattern
+		}
+	}
+}
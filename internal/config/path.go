@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PathSegment represents a single segment of a mock request path
+type PathSegment struct {
+	// Literal text the segment must equal, empty for parameter segments
+	Literal string
+	// Name of the captured parameter, empty for literal segments
+	Param string
+	// Whether the parameter captures the remainder of the path ({name...})
+	CatchAll bool
+}
+
+// IsPathTemplate reports whether the path contains parameter segments like {id}
+func IsPathTemplate(path string) bool {
+	return strings.ContainsAny(path, "{}")
+}
+
+// ParsePathTemplate splits a templated path such as /api/users/{id} or
+// /files/{path...} into its segments
+func ParsePathTemplate(path string) ([]PathSegment, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path template '%s' must start with '/'", path)
+	}
+
+	parts := strings.Split(path[1:], "/")
+	segments := make([]PathSegment, 0, len(parts))
+	seen := make(map[string]bool)
+
+	for i, part := range parts {
+		if !strings.ContainsAny(part, "{}") {
+			segments = append(segments, PathSegment{Literal: part})
+			continue
+		}
+
+		// Parameters must occupy the whole segment
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") || strings.Count(part, "{") != 1 || strings.Count(part, "}") != 1 {
+			return nil, fmt.Errorf("segment '%s' must be a literal or a single {name} parameter", part)
+		}
+
+		name := part[1 : len(part)-1]
+		catchAll := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+
+		if name == "" {
+			return nil, fmt.Errorf("segment '%s' has an empty parameter name", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate parameter name '%s'", name)
+		}
+		if catchAll && i != len(parts)-1 {
+			return nil, fmt.Errorf("catch-all parameter '%s' must be the last segment", name)
+		}
+		seen[name] = true
+
+		segments = append(segments, PathSegment{Param: name, CatchAll: catchAll})
+	}
+
+	return segments, nil
+}
+
+// MatchPathSegments checks a request path against parsed path template segments,
+// capturing the values of the template parameters
+func MatchPathSegments(segments []PathSegment, path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	parts := strings.Split(path[1:], "/")
+	params := make(map[string]string)
+
+	for i, segment := range segments {
+		if segment.CatchAll {
+			if i >= len(parts) {
+				return nil, false
+			}
+			params[segment.Param] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		if segment.Param != "" {
+			// A parameter must capture a non-empty segment
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[segment.Param] = parts[i]
+			continue
+		}
+
+		if parts[i] != segment.Literal {
+			return nil, false
+		}
+	}
+
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// CompilePathRegex compiles a pathRegex expression so that it must match the whole request path.
+// Named groups such as (?P<id>[0-9]+) are captured as path parameters.
+func CompilePathRegex(expr string) (*regexp.Regexp, error) {
+	// Compile the bare expression first so errors refer to what the user wrote
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// MediaTypes returns the media types of the response's representations in sorted order
+func (r ResponseConfig) MediaTypes() []string {
+	mediaTypes := make([]string, 0, len(r.Representations))
+	for mediaType := range r.Representations {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// Representation returns the response with the representation for the media type in place
+// of its body. The representation's status code and headers take precedence over the
+// response's, and its media type is the content type unless its headers set one.
+func (r ResponseConfig) Representation(mediaType string) ResponseConfig {
+	representation := r.Representations[mediaType]
+	if representation.StatusCode == 0 {
+		representation.StatusCode = r.StatusCode
+	}
+
+	headers := make(map[string]string, len(r.Headers)+len(representation.Headers)+1)
+	templates := make(map[string]*template.Template)
+	hasContentType := false
+	for _, source := range []ResponseConfig{r, representation} {
+		for name, value := range source.Headers {
+			// Header names are case-insensitive, so a representation's header replaces the response's
+			for existing := range headers {
+				if strings.EqualFold(existing, name) {
+					delete(headers, existing)
+					delete(templates, existing)
+				}
+			}
+			headers[name] = value
+			if tmpl := source.HeaderTemplates[name]; tmpl != nil {
+				templates[name] = tmpl
+			}
+			if strings.EqualFold(name, "Content-Type") {
+				hasContentType = true
+			}
+		}
+	}
+	if !hasContentType {
+		headers["Content-Type"] = mediaType
+	}
+	representation.Headers = headers
+	representation.HeaderTemplates = templates
+
+	if representation.Seed == "" {
+		representation.Seed, representation.SeedTemplate = r.Seed, r.SeedTemplate
+	}
+	representation.Representations = nil
+	return representation
+}
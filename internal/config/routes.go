@@ -0,0 +1,124 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// RouteTable indexes mocks by method and path so that a request is only checked
+// against the mocks that could match it
+type RouteTable struct {
+	// methods maps each mock method to its path trie, "" holds mocks for any method
+	methods map[string]*routeNode
+}
+
+// routeNode is a node of a path trie, one level per path segment
+type routeNode struct {
+	// literals holds the children for literal segments
+	literals map[string]*routeNode
+	// param holds the child for {name} parameter segments
+	param *routeNode
+	// mocks holds the mocks whose path ends at this node
+	mocks []int
+	// catchAll holds the mocks whose path ends in a {name...} parameter at this node
+	catchAll []int
+	// unindexed holds, on root nodes, the mocks with a path regex or no path,
+	// which are checked against every request for the method
+	unindexed []int
+}
+
+// NewRouteTable builds the route index for the mocks, identified by their position
+func NewRouteTable(mocks []MockConfig) *RouteTable {
+	t := &RouteTable{methods: make(map[string]*routeNode)}
+	for i, mock := range mocks {
+		root, exists := t.methods[mock.Request.Method]
+		if !exists {
+			root = &routeNode{}
+			t.methods[mock.Request.Method] = root
+		}
+		root.insert(mock.Request, i)
+	}
+	return t
+}
+
+// insert adds a mock to the trie below the root node
+func (n *routeNode) insert(req RequestConfig, index int) {
+	segments := req.PathSegments
+	if segments == nil {
+		// Exact paths, and templates that failed to parse which only match literally
+		if req.PathRegex != "" || !strings.HasPrefix(req.Path, "/") {
+			n.unindexed = append(n.unindexed, index)
+			return
+		}
+		for _, part := range strings.Split(req.Path[1:], "/") {
+			segments = append(segments, PathSegment{Literal: part})
+		}
+	}
+
+	node := n
+	for _, segment := range segments {
+		switch {
+		case segment.CatchAll:
+			node.catchAll = append(node.catchAll, index)
+			return
+		case segment.Param != "":
+			if node.param == nil {
+				node.param = &routeNode{}
+			}
+			node = node.param
+		default:
+			if node.literals == nil {
+				node.literals = make(map[string]*routeNode)
+			}
+			child, exists := node.literals[segment.Literal]
+			if !exists {
+				child = &routeNode{}
+				node.literals[segment.Literal] = child
+			}
+			node = child
+		}
+	}
+	node.mocks = append(node.mocks, index)
+}
+
+// Lookup returns the positions of the mocks that may match the method and path, in file order
+func (t *RouteTable) Lookup(method, path string) []int {
+	var indices []int
+
+	methods := []string{method}
+	if method != "" {
+		methods = append(methods, "")
+	}
+	for _, m := range methods {
+		root, exists := t.methods[m]
+		if !exists {
+			continue
+		}
+		indices = append(indices, root.unindexed...)
+		if strings.HasPrefix(path, "/") {
+			root.collect(strings.Split(path[1:], "/"), &indices)
+		}
+	}
+
+	sort.Ints(indices)
+	return indices
+}
+
+// collect adds the mocks below the node that match the remaining path segments
+func (n *routeNode) collect(parts []string, indices *[]int) {
+	if len(parts) == 0 {
+		*indices = append(*indices, n.mocks...)
+		return
+	}
+
+	// A catch-all parameter captures one or more remaining segments
+	*indices = append(*indices, n.catchAll...)
+
+	if child, exists := n.literals[parts[0]]; exists {
+		child.collect(parts[1:], indices)
+	}
+	// A parameter must capture a non-empty segment
+	if n.param != nil && parts[0] != "" {
+		n.param.collect(parts[1:], indices)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+// Path kinds ranked from least to most specific
+const (
+	PathKindAny = iota
+	PathKindRegex
+	PathKindTemplate
+	PathKindExact
+)
+
+// Specificity ranks mocks that match the same request. Fields are compared in
+// order: explicit priority, path kind, literal template segments, then matchers.
+type Specificity struct {
+	Priority        int
+	PathKind        int
+	LiteralSegments int
+	Matchers        int
+}
+
+// Compare returns a positive number if s ranks ahead of other, negative if it
+// ranks behind and zero if they are equally specific
+func (s Specificity) Compare(other Specificity) int {
+	switch {
+	case s.Priority != other.Priority:
+		return s.Priority - other.Priority
+	case s.PathKind != other.PathKind:
+		return s.PathKind - other.PathKind
+	case s.LiteralSegments != other.LiteralSegments:
+		return s.LiteralSegments - other.LiteralSegments
+	default:
+		return s.Matchers - other.Matchers
+	}
+}
+
+// Specificity computes the mock's rank for selecting between several matching mocks
+func (m MockConfig) Specificity() Specificity {
+	spec := Specificity{
+		Priority: m.Priority,
+		Matchers: m.Request.MatcherCount(),
+	}
+
+	switch {
+	case m.Request.PathRegex != "":
+		spec.PathKind = PathKindRegex
+	case m.Request.Path == "":
+		// The path is left to combinators, if constrained at all
+		spec.PathKind = PathKindAny
+	case IsPathTemplate(m.Request.Path):
+		spec.PathKind = PathKindTemplate
+		for _, segment := range m.Request.PathSegments {
+			if segment.Param == "" {
+				spec.LiteralSegments++
+			}
+		}
+	default:
+		spec.PathKind = PathKindExact
+	}
+
+	return spec
+}
+
+// MatcherCount returns the number of criteria beyond path and method.
+// Each nested request in anyOf, allOf or not counts as one criterion.
+func (r RequestConfig) MatcherCount() int {
+	count := len(r.Query) + len(r.Headers) + len(r.Cookies) + len(r.BodyMatchers) +
+		len(r.XPath) + len(r.Form) + len(r.Multipart) + len(r.AnyOf) + len(r.AllOf)
+
+	for _, set := range []bool{r.Body != nil, r.BodyEquals != nil, r.BodyContains != "", r.BodyRegex != "", r.Not != nil} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"text/template"
+
+	"mock-harbor/internal/templating"
+)
+
+// StreamConfig describes a response body written over time, either as the chunks
+// of a chunked response or as Server-Sent Events
+type StreamConfig struct {
+	// Chunks are written in order, each flushed to the client as it is written
+	Chunks []StreamChunk `json:"chunks,omitempty" yaml:"chunks,omitempty"`
+	// Events are written in order in the text/event-stream format
+	Events []StreamEvent `json:"events,omitempty" yaml:"events,omitempty"`
+	// Repeat is how many times the chunks or events are written, -1 to repeat until the client disconnects
+	Repeat int `json:"repeat,omitempty" yaml:"repeat,omitempty"`
+	// KeepAlive writes an SSE comment every this many milliseconds after the last event,
+	// holding the connection open until the client disconnects
+	KeepAlive int `json:"keepAlive,omitempty" yaml:"keepAlive,omitempty"`
+}
+
+// StreamChunk is a piece of a chunked response body
+type StreamChunk struct {
+	// Delay in milliseconds before the chunk is written
+	Delay int `json:"delay,omitempty" yaml:"delay,omitempty"`
+	// Data is written as is and may be a template
+	Data string `json:"data" yaml:"data"`
+
+	// DataTemplate holds the parsed Data if it is templated, populated at load time
+	DataTemplate *template.Template `json:"-" yaml:"-"`
+}
+
+// StreamEvent is a Server-Sent Event
+type StreamEvent struct {
+	// Delay in milliseconds before the event is written
+	Delay int `json:"delay,omitempty" yaml:"delay,omitempty"`
+	// Event is the event type, message if unset
+	Event string `json:"event,omitempty" yaml:"event,omitempty"`
+	// ID sets the client's last event ID and may be a template
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Retry tells the client how many milliseconds to wait before reconnecting
+	Retry int `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Data is sent as is if it is a string and encoded as JSON otherwise
+	Data interface{} `json:"data,omitempty" yaml:"data,omitempty"`
+
+	// DataTemplate holds Data with templated strings parsed, populated at load time
+	DataTemplate interface{} `json:"-" yaml:"-"`
+	// IDTemplate holds the parsed ID if it is templated, populated at load time
+	IDTemplate *template.Template `json:"-" yaml:"-"`
+}
+
+// IsSSE reports whether the stream is written as Server-Sent Events
+func (s *StreamConfig) IsSSE() bool {
+	return len(s.Events) > 0
+}
+
+// compile parses the templates in the stream once at load time.
+// Invalid templates are left unparsed and reported by validation.
+func (s *StreamConfig) compile() {
+	for i := range s.Chunks {
+		chunk := &s.Chunks[i]
+		chunk.DataTemplate = nil
+		if templating.IsTemplate(chunk.Data) {
+			if tmpl, err := templating.Parse(fmt.Sprintf("stream.chunks[%d].data", i), chunk.Data); err == nil {
+				chunk.DataTemplate = tmpl
+			}
+		}
+	}
+
+	for i := range s.Events {
+		event := &s.Events[i]
+		event.DataTemplate = nil
+		if event.Data != nil {
+			if data, err := CompileTemplateValue(fmt.Sprintf("stream.events[%d].data", i), event.Data); err == nil {
+				event.DataTemplate = data
+			}
+		}
+
+		event.IDTemplate = nil
+		if templating.IsTemplate(event.ID) {
+			if tmpl, err := templating.Parse(fmt.Sprintf("stream.events[%d].id", i), event.ID); err == nil {
+				event.IDTemplate = tmpl
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"mock-harbor/internal/templating"
+)
+
+// WebSocketConfig scripts the conversation of a mock WebSocket endpoint
+type WebSocketConfig struct {
+	// Protocol is the subprotocol accepted when the client offers it
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	// OnOpen messages are sent in order once the connection is open
+	OnOpen []WebSocketMessage `json:"onOpen,omitempty" yaml:"onOpen,omitempty"`
+	// Timers send messages at intervals while the connection is open
+	Timers []WebSocketTimer `json:"timers,omitempty" yaml:"timers,omitempty"`
+	// Rules reply to the messages the client sends, the first matching rule wins
+	Rules []WebSocketRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Close closes the connection once its delay has passed since the connection opened
+	Close *WebSocketClose `json:"close,omitempty" yaml:"close,omitempty"`
+}
+
+// WebSocketMessage is a message sent to the client, set by exactly one of Text, JSON and Binary
+type WebSocketMessage struct {
+	// Delay in milliseconds before the message is sent
+	Delay int `json:"delay,omitempty" yaml:"delay,omitempty"`
+	// Text is sent as a text message and may be a template
+	Text string `json:"text,omitempty" yaml:"text,omitempty"`
+	// JSON is encoded and sent as a text message, its strings may be templates
+	JSON interface{} `json:"json,omitempty" yaml:"json,omitempty"`
+	// Binary is sent as a binary message, base64-encoded
+	Binary string `json:"binary,omitempty" yaml:"binary,omitempty"`
+
+	// TextTemplate holds the parsed Text if it is templated, populated at load time
+	TextTemplate *template.Template `json:"-" yaml:"-"`
+	// JSONTemplate holds JSON with templated strings parsed, populated at load time
+	JSONTemplate interface{} `json:"-" yaml:"-"`
+	// BinaryBytes holds the decoded Binary, populated at load time
+	BinaryBytes []byte `json:"-" yaml:"-"`
+}
+
+// WebSocketTimer sends a message at a fixed interval
+type WebSocketTimer struct {
+	// Interval in milliseconds between messages, the first is sent one interval after the connection opens
+	Interval int `json:"interval" yaml:"interval"`
+	// Count limits how many messages are sent, unlimited if unset
+	Count   int              `json:"count,omitempty" yaml:"count,omitempty"`
+	Message WebSocketMessage `json:"message" yaml:"message"`
+}
+
+// WebSocketRule replies to client messages matching its criteria, or to any message without criteria
+type WebSocketRule struct {
+	// JSON matches JSON messages containing these fields and values
+	JSON interface{} `json:"json,omitempty" yaml:"json,omitempty"`
+	// Regex matches messages against a regular expression
+	Regex string `json:"regex,omitempty" yaml:"regex,omitempty"`
+	// Reply messages are sent in order when the rule matches
+	Reply []WebSocketMessage `json:"reply,omitempty" yaml:"reply,omitempty"`
+	// Close closes the connection once its delay has passed after the replies
+	Close *WebSocketClose `json:"close,omitempty" yaml:"close,omitempty"`
+
+	// Pattern holds the compiled Regex, populated at load time
+	Pattern *regexp.Regexp `json:"-" yaml:"-"`
+}
+
+// WebSocketClose closes the connection with a close code
+type WebSocketClose struct {
+	// Delay in milliseconds before the connection is closed
+	Delay int `json:"delay,omitempty" yaml:"delay,omitempty"`
+	// Code is sent in the close frame, 1000 if unset
+	Code   int    `json:"code,omitempty" yaml:"code,omitempty"`
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// compile prepares the messages and rules of the conversation once at load time.
+// Invalid templates, patterns and encodings are left uncompiled and reported by validation.
+func (ws *WebSocketConfig) compile() {
+	for i := range ws.OnOpen {
+		ws.OnOpen[i].compile(fmt.Sprintf("websocket.onOpen[%d]", i))
+	}
+	for i := range ws.Timers {
+		ws.Timers[i].Message.compile(fmt.Sprintf("websocket.timers[%d].message", i))
+	}
+	for i := range ws.Rules {
+		rule := &ws.Rules[i]
+		rule.Pattern = nil
+		if rule.Regex != "" {
+			if pattern, err := regexp.Compile(rule.Regex); err == nil {
+				rule.Pattern = pattern
+			}
+		}
+		for j := range rule.Reply {
+			rule.Reply[j].compile(fmt.Sprintf("websocket.rules[%d].reply[%d]", i, j))
+		}
+	}
+}
+
+// compile parses the templates of the message and decodes its binary content
+func (m *WebSocketMessage) compile(name string) {
+	m.TextTemplate = nil
+	if templating.IsTemplate(m.Text) {
+		if tmpl, err := templating.Parse(name+".text", m.Text); err == nil {
+			m.TextTemplate = tmpl
+		}
+	}
+
+	m.JSONTemplate = nil
+	if m.JSON != nil {
+		if value, err := CompileTemplateValue(name+".json", m.JSON); err == nil {
+			m.JSONTemplate = value
+		}
+	}
+
+	m.BinaryBytes = nil
+	if m.Binary != "" {
+		if content, err := base64.StdEncoding.DecodeString(m.Binary); err == nil {
+			m.BinaryBytes = content
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"mock-harbor/internal/config"
+)
+
+// hasBodyCriteria reports whether the mock needs to inspect the request body
+func hasBodyCriteria(req config.RequestConfig) bool {
+	return req.Body != nil || len(req.BodyMatchers) > 0 || len(req.XPath) > 0 || len(req.Form) > 0 || len(req.Multipart) > 0 ||
+		req.BodyEquals != nil || req.BodyContains != "" || req.BodyRegex != ""
+}
+
+// matchBody checks the raw request body against the mock's body criteria, decoding
+// it through the incoming request so each format is parsed at most once
+func matchBody(req config.RequestConfig, in *incomingRequest, body []byte, report *matchReport) {
+	// Raw text criteria apply to any payload
+	matchRawBody(req, string(body), report)
+
+	// JSON body criteria
+	if req.Body != nil || len(req.BodyMatchers) > 0 {
+		requestBody, err := in.JSON()
+		if err != nil {
+			report.fail("body", "a JSON body", fmt.Sprintf("invalid JSON: %v", err))
+		} else {
+			if req.Body != nil && !matchesMockBody(requestBody, req.Body, req.ArrayMatch) {
+				report.fail("body", describeJSON(req.Body), truncate(string(body)))
+			}
+
+			// Check the JSONPath body matchers
+			matchBodyMatchers(req.BodyMatchers, requestBody, report)
+		}
+	}
+
+	// XML body criteria
+	if len(req.XPath) > 0 {
+		doc, err := in.XML()
+		if err != nil {
+			report.fail("xpath", "an XML body", fmt.Sprintf("invalid XML: %v", err))
+		} else {
+			matchXPath(req.XPath, doc, report)
+		}
+	}
+
+	// Form and multipart body criteria
+	if len(req.Form) > 0 || len(req.Multipart) > 0 {
+		form, err := in.Form()
+		if err != nil {
+			report.fail("form", "a form body", err.Error())
+		} else {
+			matchForm(req, form, report)
+		}
+	}
+}
+
+// matchRawBody checks the request body as plain text against the mock's text criteria
+func matchRawBody(req config.RequestConfig, body string, report *matchReport) {
+	if req.BodyEquals != nil && body != *req.BodyEquals {
+		report.fail("bodyEquals", truncate(fmt.Sprintf("%q", *req.BodyEquals)), truncate(fmt.Sprintf("%q", body)))
+	}
+	if req.BodyContains != "" && !strings.Contains(body, req.BodyContains) {
+		report.fail("bodyContains", truncate(fmt.Sprintf("%q", req.BodyContains)), truncate(fmt.Sprintf("%q", body)))
+	}
+	// Patterns that failed to compile never match
+	if req.BodyRegex != "" && (req.BodyPattern == nil || !req.BodyPattern.MatchString(body)) {
+		report.fail("bodyRegex", "/"+req.BodyRegex+"/", truncate(fmt.Sprintf("%q", body)))
+	}
+}
+
+// matchBodyMatchers checks the decoded JSON request body against the mock's JSONPath matchers
+func matchBodyMatchers(matchers []config.BodyMatcher, body interface{}, report *matchReport) {
+	for i, matcher := range matchers {
+		// Expressions that failed to compile never match
+		var selected []interface{}
+		if matcher.Selector != nil {
+			selected = matcher.Selector.Select(body)
+		}
+
+		if matcher.Selector == nil || !evaluateOperator(matcher.Operator(), matcher.Value, matcher.Pattern, selected) {
+			report.fail(fmt.Sprintf("bodyMatchers[%d]", i), describeOperator(matcher.Path, matcher.Operator(), matcher.Value), describeSelected(selected))
+		}
+	}
+}
+
+// describeOperator formats a body matcher for mismatch reports
+func describeOperator(expr, op string, value interface{}) string {
+	if op == config.OpExists || op == config.OpAbsent {
+		return expr + " " + op
+	}
+	return expr + " " + op + " " + describeJSON(value)
+}
+
+// describeSelected formats the values selected by a body matcher for mismatch reports
+func describeSelected(selected []interface{}) string {
+	switch len(selected) {
+	case 0:
+		return "nothing selected"
+	case 1:
+		return describeJSON(selected[0])
+	}
+	return describeJSON(selected)
+}
+
+// evaluateOperator applies a matcher operator to the values selected by its expression
+func evaluateOperator(op string, expected interface{}, pattern *regexp.Regexp, selected []interface{}) bool {
+	switch op {
+	case config.OpExists:
+		return len(selected) > 0
+	case config.OpAbsent:
+		return len(selected) == 0
+	case config.OpNotEquals:
+		for _, value := range selected {
+			if jsonEqual(value, expected) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range selected {
+		if compareValue(op, expected, pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValue applies a value operator to a single selected value
+func compareValue(op string, expected interface{}, pattern *regexp.Regexp, value interface{}) bool {
+	switch op {
+	case config.OpEquals:
+		return jsonEqual(value, expected)
+	case config.OpContains:
+		switch v := value.(type) {
+		case string:
+			substring, ok := expected.(string)
+			return ok && strings.Contains(v, substring)
+		case []interface{}:
+			for _, item := range v {
+				if jsonEqual(item, expected) {
+					return true
+				}
+			}
+		case map[string]interface{}:
+			key, ok := expected.(string)
+			if ok {
+				_, exists := v[key]
+				return exists
+			}
+		}
+		return false
+	case config.OpRegex:
+		text, ok := scalarString(value)
+		return ok && pattern != nil && pattern.MatchString(text)
+	case config.OpGt, config.OpLt:
+		order, ok := compareOrdered(value, expected)
+		if !ok {
+			return false
+		}
+		if op == config.OpGt {
+			return order > 0
+		}
+		return order < 0
+	case config.OpIn:
+		candidates, ok := expected.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, candidate := range candidates {
+			if jsonEqual(value, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesArray compares a received array with the expected array according to the
+// array match mode; elements are compared with the same rules as the body itself
+func matchesArray(received, expected []interface{}, mode string) bool {
+	switch mode {
+	case config.ArrayMatchUnordered:
+		return len(received) == len(expected) && matchElements(received, expected, mode)
+	case config.ArrayMatchSubset:
+		return len(received) >= len(expected) && matchElements(received, expected, mode)
+	default:
+		if len(received) != len(expected) {
+			return false
+		}
+		for i := range expected {
+			if !matchesMockBody(received[i], expected[i], mode) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// matchElements reports whether every expected element can be paired with a distinct
+// received element that matches it, regardless of order
+func matchElements(received, expected []interface{}, mode string) bool {
+	// pairedWith[j] is the index of the expected element paired with received[j], or -1
+	pairedWith := make([]int, len(received))
+	for j := range pairedWith {
+		pairedWith[j] = -1
+	}
+
+	// Find an augmenting path for each expected element in turn
+	var assign func(i int, visited []bool) bool
+	assign = func(i int, visited []bool) bool {
+		for j := range received {
+			if visited[j] || !matchesMockBody(received[j], expected[i], mode) {
+				continue
+			}
+			visited[j] = true
+			if pairedWith[j] < 0 || assign(pairedWith[j], visited) {
+				pairedWith[j] = i
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range expected {
+		if !assign(i, make([]bool, len(received))) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonEqual compares two decoded JSON values
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// scalarString formats strings, numbers and booleans for regex matching
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// compareOrdered compares two numbers, or two strings lexically, returning -1, 0 or 1
+func compareOrdered(a, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case av < bv:
+			return -1, true
+		case av > bv:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	}
+	return 0, false
+}
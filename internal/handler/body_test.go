@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const bodyMatcherTestBody = `{
+	"email": "ana@example.com",
+	"status": "active",
+	"nickname": null,
+	"items": [
+		{"sku": "A-1", "qty": 2, "tags": ["new", "sale"]},
+		{"sku": "B-2", "qty": 5}
+	],
+	"owner": {"id": 7, "roles": {"admin": true}}
+}`
+
+func TestBodyMatcherOperators(t *testing.T) {
+	tests := []struct {
+		matcher string
+		want    bool
+	}{
+		// equals is the default operator
+		{`{"path": "$.status", "value": "active"}`, true},
+		{`{"path": "$.items[0].qty", "op": "equals", "value": 2}`, true},
+		{`{"path": "$.items[0].qty", "op": "equals", "value": "2"}`, false},
+		{`{"path": "$.items[*].sku", "op": "equals", "value": "B-2"}`, true},
+		{`{"path": "$.owner.roles", "op": "equals", "value": {"admin": true}}`, true},
+		{`{"path": "$.missing", "op": "equals", "value": "x"}`, false},
+
+		{`{"path": "$.status", "op": "notEquals", "value": "inactive"}`, true},
+		{`{"path": "$.status", "op": "notEquals", "value": "active"}`, false},
+		{`{"path": "$.items[*].sku", "op": "notEquals", "value": "B-2"}`, false},
+		{`{"path": "$.missing", "op": "notEquals", "value": "x"}`, true},
+
+		{`{"path": "$.email", "op": "contains", "value": "@example"}`, true},
+		{`{"path": "$.email", "op": "contains", "value": "@test"}`, false},
+		{`{"path": "$.items[0].tags", "op": "contains", "value": "sale"}`, true},
+		{`{"path": "$.items[0].tags", "op": "contains", "value": "old"}`, false},
+		{`{"path": "$.owner.roles", "op": "contains", "value": "admin"}`, true},
+		{`{"path": "$.owner.roles", "op": "contains", "value": "guest"}`, false},
+
+		{`{"path": "$.email", "op": "regex", "value": "^[a-z]+@example\\.com$"}`, true},
+		{`{"path": "$.email", "op": "regex", "value": "^bob@"}`, false},
+		{`{"path": "$.owner.id", "op": "regex", "value": "^[0-9]$"}`, true},
+		{`{"path": "$.owner", "op": "regex", "value": ".*"}`, false},
+		{`{"path": "$.email", "op": "regex", "value": "("}`, false},
+
+		{`{"path": "$.nickname", "op": "exists"}`, true},
+		{`{"path": "$.items[1].sku", "op": "exists"}`, true},
+		{`{"path": "$.items[2]", "op": "exists"}`, false},
+		{`{"path": "$..tags", "op": "exists"}`, true},
+
+		{`{"path": "$.phone", "op": "absent"}`, true},
+		{`{"path": "$.nickname", "op": "absent"}`, false},
+		{`{"path": "$.items[5].sku", "op": "absent"}`, true},
+
+		{`{"path": "$.items[0].qty", "op": "gt", "value": 1}`, true},
+		{`{"path": "$.items[0].qty", "op": "gt", "value": 2}`, false},
+		{`{"path": "$.items[*].qty", "op": "gt", "value": 4}`, true},
+		{`{"path": "$.status", "op": "gt", "value": "abc"}`, true},
+		{`{"path": "$.status", "op": "gt", "value": 1}`, false},
+
+		{`{"path": "$.items[0].qty", "op": "lt", "value": 3}`, true},
+		{`{"path": "$.items[1].qty", "op": "lt", "value": 5}`, false},
+		{`{"path": "$.email", "op": "lt", "value": "b"}`, true},
+
+		{`{"path": "$.status", "op": "in", "value": ["active", "pending"]}`, true},
+		{`{"path": "$.status", "op": "in", "value": ["closed"]}`, false},
+		{`{"path": "$.owner.id", "op": "in", "value": [1, 7]}`, true},
+		{`{"path": "$.status", "op": "in", "value": "active"}`, false},
+
+		{`{"path": "$['items'][0]['sku']", "value": "A-1"}`, true},
+		{`{"path": "$..*", "op": "contains", "value": "new"}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.matcher, func(t *testing.T) {
+			mocks := loadTestMocks(t, fmt.Sprintf(`[{"request": {"method": "POST", "path": "/orders", "bodyMatchers": [%s]}, "response": {"statusCode": 200}}]`, tt.matcher))
+			r := httptest.NewRequest("POST", "/orders", strings.NewReader(bodyMatcherTestBody))
+			r.Header.Set("Content-Type", "application/json")
+
+			_, matched := matchRequest(mocks[0].Request, newIncomingRequest(r))
+			if matched != tt.want {
+				t.Errorf("matched = %v, want %v", matched, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyMatcherReportsSelectedValues(t *testing.T) {
+	mocks := loadTestMocks(t, `[{"request": {"method": "POST", "path": "/orders", "bodyMatchers": [
+		{"path": "$.items[*].qty", "op": "gt", "value": 9},
+		{"path": "$.phone", "op": "exists"}
+	]}, "response": {"statusCode": 200}}]`)
+	r := httptest.NewRequest("POST", "/orders", strings.NewReader(bodyMatcherTestBody))
+	r.Header.Set("Content-Type", "application/json")
+
+	report := &matchReport{exhaustive: true}
+	evaluateRequest(mocks[0].Request, newIncomingRequest(r), report)
+
+	want := []mismatch{
+		{Criterion: "bodyMatchers[0]", Expected: "$.items[*].qty gt 9", Actual: "[2,5]"},
+		{Criterion: "bodyMatchers[1]", Expected: "$.phone exists", Actual: "nothing selected"},
+	}
+	if fmt.Sprint(report.failures) != fmt.Sprint(want) {
+		t.Errorf("failures = %v, want %v", report.failures, want)
+	}
+}
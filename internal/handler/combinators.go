@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"mock-harbor/internal/config"
+)
+
+// matchCombinators checks the nested anyOf, allOf and not criteria against the request,
+// recording failures under the combinator, and returns the path parameters merged with
+// any captured by matching nested criteria
+func matchCombinators(req config.RequestConfig, in *incomingRequest, params map[string]string, report *matchReport) map[string]string {
+	// Every nested request in allOf must match, failures are reported individually
+	for i, nested := range req.AllOf {
+		nestedReport := &matchReport{exhaustive: report.exhaustive}
+		nestedParams := evaluateRequest(nested, in, nestedReport)
+		if len(nestedReport.failures) == 0 {
+			params = mergeParams(params, nestedParams)
+			continue
+		}
+		for _, failure := range nestedReport.failures {
+			report.fail(fmt.Sprintf("allOf[%d].%s", i, failure.Criterion), failure.Expected, failure.Actual)
+		}
+		if report.done() {
+			return nil
+		}
+	}
+
+	// At least one nested request in anyOf must match, the first that does wins
+	if len(req.AnyOf) > 0 {
+		var missed []string
+		matched := false
+		for i, nested := range req.AnyOf {
+			nestedParams, ok := matchRequest(nested, in)
+			if ok {
+				params = mergeParams(params, nestedParams)
+				matched = true
+				break
+			}
+			missed = append(missed, fmt.Sprintf("[%d]", i))
+		}
+		if !matched {
+			report.fail("anyOf", fmt.Sprintf("one of %d alternatives", len(req.AnyOf)), "no match for "+strings.Join(missed, ", "))
+			if report.done() {
+				return nil
+			}
+		}
+	}
+
+	// The nested request in not must not match
+	if req.Not != nil {
+		if _, ok := matchRequest(*req.Not, in); ok {
+			report.fail("not", "negated criteria not to match", "matched")
+		}
+	}
+
+	return params
+}
+
+// mergeParams adds the path parameters captured by nested criteria to those already captured
+func mergeParams(params, nested map[string]string) map[string]string {
+	if len(nested) == 0 {
+		return params
+	}
+	if params == nil {
+		params = make(map[string]string, len(nested))
+	}
+	for name, value := range nested {
+		params[name] = value
+	}
+	return params
+}
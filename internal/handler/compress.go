@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mock-harbor/internal/config"
+)
+
+// encodingNotAcceptableError reports a body that can't be sent in any encoding the
+// request accepts: a precompressed body file that can't be decoded, which is the
+// case for Brotli since the standard library has no implementation of it, or a body
+// that would be sent unencoded to a request refusing identity
+type encodingNotAcceptableError struct {
+	reason    string
+	available []string
+}
+
+func (e *encodingNotAcceptableError) Error() string {
+	return "no acceptable encoding, " + e.reason
+}
+
+// precompressedExtensions maps the extensions of compressed body files to their encodings
+var precompressedExtensions = map[string]string{
+	".gz": config.EncodingGzip,
+	".br": "br",
+}
+
+// compressBody encodes the response body in the encoding the request accepts best and
+// labels it with Content-Encoding and Vary, returning the body and its content type.
+// Bodies that are small, already encoded or of a type that doesn't compress are sent
+// as they are. Body files with a .gz or .br extension are already compressed, and are
+// sent with the encoding and content type their name describes even if compression
+// is disabled. Gzip files are decompressed for clients whose Accept-Encoding doesn't
+// accept gzip, while requests without Accept-Encoding accept any encoding.
+func (rr *responseRenderer) compressBody(w http.ResponseWriter, compression *config.CompressionConfig, body []byte, contentType string) ([]byte, string, error) {
+	header := w.Header()
+	enabled := compression != nil && compression.Enabled
+	ext := filepath.Ext(rr.bodyFile)
+	precompressed := precompressedExtensions[ext]
+	if (!enabled && precompressed == "") || len(body) == 0 || header.Get("Content-Encoding") != "" {
+		return body, contentType, nil
+	}
+	addVary(header, "Accept-Encoding")
+	values := rr.in.r.Header.Values("Accept-Encoding")
+	accepted := parseAcceptEncoding(values)
+
+	encoding := ""
+	if precompressed != "" {
+		encoding = precompressed
+		if inner := mime.TypeByExtension(filepath.Ext(strings.TrimSuffix(rr.bodyFile, ext))); inner != "" {
+			contentType = inner
+		}
+
+		switch {
+		case values == nil:
+			// Requests without Accept-Encoding accept any encoding
+		case accepted.weight(encoding) > 0:
+		case encoding == config.EncodingGzip:
+			// Clients that don't accept gzip get the file decompressed, and maybe compressed again below
+			decompressed, err := decompressGzip(body)
+			if err != nil {
+				return nil, "", err
+			}
+			body, encoding = decompressed, ""
+		default:
+			return nil, "", &encodingNotAcceptableError{
+				reason:    fmt.Sprintf("%s is only available with Content-Encoding %s", rr.bodyFile, encoding),
+				available: []string{encoding},
+			}
+		}
+	}
+
+	// A content type set by the mock takes precedence over the one for the body
+	mediaType := header.Get("Content-Type")
+	if mediaType == "" {
+		mediaType = contentType
+	}
+	available := []string{"identity"}
+	if precompressed != "" {
+		available = []string{precompressed}
+	}
+	if enabled && encoding == "" && len(body) >= compression.MinSize && compressible(mediaType) {
+		available = compression.SupportedEncodings()
+		encoding = accepted.negotiate(available)
+		if encoding != "" {
+			compressed, err := compress(body, encoding)
+			if err != nil {
+				return nil, "", err
+			}
+			body = compressed
+		}
+	}
+	if encoding == "" && !accepted.identityAcceptable() {
+		return nil, "", &encodingNotAcceptableError{reason: "the body can't be sent unencoded", available: available}
+	}
+
+	if enabled && compression.WrongEncoding != "" {
+		log.Printf("Labeling %s response body as %s", encodingName(encoding), compression.WrongEncoding)
+		header.Set("Content-Encoding", compression.WrongEncoding)
+	} else if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	return body, contentType, nil
+}
+
+// acceptedEncodings maps the content codings listed by Accept-Encoding headers to their quality values
+type acceptedEncodings map[string]float64
+
+// parseAcceptEncoding parses Accept-Encoding headers, skipping malformed entries
+func parseAcceptEncoding(values []string) acceptedEncodings {
+	accepted := make(acceptedEncodings)
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(item, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			q := 1.0
+			if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || parsed < 0 || parsed > 1 {
+					continue
+				}
+				q = parsed
+			}
+			accepted[name] = q
+		}
+	}
+	return accepted
+}
+
+// weight returns the quality value of an encoding, falling back to that of * and 0 if neither is listed
+func (a acceptedEncodings) weight(encoding string) float64 {
+	if q, listed := a[encoding]; listed {
+		return q
+	}
+	return a["*"]
+}
+
+// identityAcceptable reports whether a body may be sent unencoded, which it may
+// unless identity, or * with identity not listed, has a quality value of 0
+func (a acceptedEncodings) identityAcceptable() bool {
+	if q, listed := a["identity"]; listed {
+		return q > 0
+	}
+	if q, listed := a["*"]; listed {
+		return q > 0
+	}
+	return true
+}
+
+// negotiate picks the supported encoding with the highest quality value, preferring
+// the one listed first on ties, or returns an empty string if none is accepted
+func (a acceptedEncodings) negotiate(supported []string) string {
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		encoding = strings.ToLower(encoding)
+		if q := a.weight(encoding); q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether bodies of the content type shrink when compressed,
+// which isn't the case for most image, audio, video and archive formats
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	switch {
+	case mediaType == "image/svg+xml":
+		return true
+	case mainType == "image" || mainType == "audio" || mainType == "video":
+		return false
+	}
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/zip", "application/x-brotli", "application/zstd", "font/woff", "font/woff2":
+		return false
+	}
+	return true
+}
+
+// compress encodes a body with gzip or deflate, the latter in the zlib format HTTP uses
+func compress(body []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	if encoding == config.EncodingDeflate {
+		writer = zlib.NewWriter(&buf)
+	} else {
+		writer = gzip.NewWriter(&buf)
+	}
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressGzip decodes a gzip-compressed body
+func decompressGzip(body []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// encodingName describes an encoding for logging, identity if the body isn't encoded
+func encodingName(encoding string) string {
+	if encoding == "" {
+		return "identity"
+	}
+	return encoding
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"mock-harbor/internal/config"
+)
+
+// defaultNearMissLimit is the number of closest mocks reported when none is configured
+const defaultNearMissLimit = 3
+
+// nearMiss describes how close one mock came to matching a request
+type nearMiss struct {
+	Usecase  string     `json:"usecase,omitempty"`
+	Index    int        `json:"index"`
+	Mock     string     `json:"mock"`
+	Failures []mismatch `json:"failures"`
+
+	pathFailed   bool
+	methodFailed bool
+}
+
+// nearMissReport is returned when no mock matches a request
+type nearMissReport struct {
+	Error        string         `json:"error"`
+	Request      requestSummary `json:"request"`
+	ClosestMocks []nearMiss     `json:"closestMocks"`
+}
+
+// requestSummary identifies the unmatched request in a near-miss report
+type requestSummary struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+}
+
+// findNearMisses checks the request against every mock of the usecases it falls
+// through and ranks them by how close they came to matching: a matching path first,
+// then a matching method, then the fewest failed criteria, with the order of the
+// usecases and their files breaking ties
+func (h *MockHandler) findNearMisses(in *incomingRequest, limit int) []nearMiss {
+	var misses []nearMiss
+	for handler := h; handler != nil; handler = handler.Next {
+		for i, mock := range handler.Mocks {
+			report := &matchReport{exhaustive: true}
+			evaluateRequest(mock.Request, in, report)
+			pathFailed, methodFailed := routeFailed(mock.Request, in)
+
+			misses = append(misses, nearMiss{
+				Usecase:      handler.Usecase,
+				Index:        i,
+				Mock:         describeMock(mock),
+				Failures:     report.failures,
+				pathFailed:   pathFailed,
+				methodFailed: methodFailed,
+			})
+		}
+	}
+
+	sort.SliceStable(misses, func(a, b int) bool {
+		if misses[a].pathFailed != misses[b].pathFailed {
+			return !misses[a].pathFailed
+		}
+		if misses[a].methodFailed != misses[b].methodFailed {
+			return !misses[a].methodFailed
+		}
+		return len(misses[a].Failures) < len(misses[b].Failures)
+	})
+
+	if len(misses) > limit {
+		misses = misses[:limit]
+	}
+	return misses
+}
+
+// routeFailed reports whether the request's path and method fail the criteria,
+// including those of combinators: a criterion fails when any request in allOf
+// fails it, or every request in anyOf does
+func routeFailed(req config.RequestConfig, in *incomingRequest) (pathFailed, methodFailed bool) {
+	if req.Path != "" || req.PathRegex != "" {
+		_, pathMatches := matchPath(req, in.r.URL.Path)
+		pathFailed = !pathMatches
+	}
+	methodFailed = req.Method != "" && in.r.Method != req.Method
+
+	for _, nested := range req.AllOf {
+		nestedPath, nestedMethod := routeFailed(nested, in)
+		pathFailed = pathFailed || nestedPath
+		methodFailed = methodFailed || nestedMethod
+	}
+	if len(req.AnyOf) > 0 {
+		anyPath, anyMethod := true, true
+		for _, nested := range req.AnyOf {
+			nestedPath, nestedMethod := routeFailed(nested, in)
+			anyPath = anyPath && nestedPath
+			anyMethod = anyMethod && nestedMethod
+		}
+		pathFailed = pathFailed || anyPath
+		methodFailed = methodFailed || anyMethod
+	}
+	return pathFailed, methodFailed
+}
+
+// writeNearMissReport logs and returns a report of the mocks closest to matching the request
+func (h *MockHandler) writeNearMissReport(w http.ResponseWriter, in *incomingRequest) {
+	r := in.r
+
+	limit := h.Diagnostics.Limit
+	if limit <= 0 {
+		limit = defaultNearMissLimit
+	}
+
+	report := nearMissReport{
+		Error: "No matching mock found",
+		Request: requestSummary{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+		},
+		ClosestMocks: h.findNearMisses(in, limit),
+	}
+
+	for _, miss := range report.ClosestMocks {
+		if miss.Usecase != "" {
+			log.Printf("  Closest mock %s[%d] %s failed on:", miss.Usecase, miss.Index, miss.Mock)
+		} else {
+			log.Printf("  Closest mock [%d] %s failed on:", miss.Index, miss.Mock)
+		}
+		for _, failure := range miss.Failures {
+			log.Printf("    - %s", failure)
+		}
+	}
+
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling near-miss report: %v", err)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No matching mock found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(body)
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"log"
+
+	"mock-harbor/internal/config"
+)
+
+// resolveMock finds the mock for a request in this usecase, then in the usecases it
+// falls through to. If none matches, it returns the fallback response of the first
+// usecase along the chain that declares one.
+func (h *MockHandler) resolveMock(in *incomingRequest) (config.MockConfig, map[string]string, bool) {
+	for current := h; current != nil; current = current.Next {
+		if mock, params, found := current.findMatchingMock(in); found {
+			return mock, params, true
+		}
+	}
+
+	for current := h; current != nil; current = current.Next {
+		if current.Fallback != nil && current.Fallback.Response != nil {
+			log.Printf("Using fallback response for request: %s %s", in.r.Method, in.r.URL.Path)
+			return config.MockConfig{Response: *current.Fallback.Response}, nil, true
+		}
+	}
+
+	return config.MockConfig{}, nil, false
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+
+	"mock-harbor/internal/config"
+)
+
+// formData holds the fields and parts decoded from a form request body
+type formData struct {
+	fields url.Values
+	parts  []formPart
+}
+
+// formPart is a single part of a multipart/form-data body
+type formPart struct {
+	name        string
+	filename    string
+	contentType string
+	content     []byte
+}
+
+// parseFormBody decodes an application/x-www-form-urlencoded or multipart/form-data
+// body, chosen by the request's Content-Type
+func parseFormBody(contentType string, body []byte) (*formData, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Type '%s': %w", contentType, err)
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		fields, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return &formData{fields: fields}, nil
+	case "multipart/form-data":
+		return parseMultipart(body, params["boundary"])
+	default:
+		return nil, fmt.Errorf("unsupported Content-Type '%s' for form matching", mediaType)
+	}
+}
+
+// parseMultipart reads every part of a multipart/form-data body. Parts without a
+// filename are also exposed as form fields.
+func parseMultipart(body []byte, boundary string) (*formData, error) {
+	if boundary == "" {
+		return nil, fmt.Errorf("multipart body without boundary")
+	}
+
+	form := &formData{fields: make(url.Values)}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed := formPart{
+			name:        part.FormName(),
+			filename:    part.FileName(),
+			contentType: part.Header.Get("Content-Type"),
+			content:     content,
+		}
+		form.parts = append(form.parts, parsed)
+
+		if parsed.filename == "" && parsed.name != "" {
+			form.fields.Add(parsed.name, string(content))
+		}
+	}
+}
+
+// matchForm checks the decoded form against the mock's form field and multipart matchers
+func matchForm(req config.RequestConfig, form *formData, report *matchReport) {
+	matchNamedValues("form", req.Form, queryLookup(form.fields), report)
+
+	// Every part matcher must be satisfied by at least one part
+	for i, partMatcher := range req.Multipart {
+		found := false
+		for _, part := range form.parts {
+			if matchPart(partMatcher, part) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(form.parts))
+			for j, part := range form.parts {
+				names[j] = part.name
+			}
+			report.fail(fmt.Sprintf("multipart[%d]", i), fmt.Sprintf("a matching part named %q", partMatcher.Name), fmt.Sprintf("parts %q", names))
+		}
+	}
+}
+
+// matchPart checks a single multipart part against a part matcher
+func matchPart(matcher config.PartMatcher, part formPart) bool {
+	if part.name != matcher.Name {
+		return false
+	}
+	return matchOptionalValue(matcher.Filename, part.filename) &&
+		matchOptionalValue(matcher.ContentType, part.contentType) &&
+		matchOptionalValue(matcher.Content, string(part.content))
+}
+
+// matchOptionalValue checks a single value against a matcher that may be unset;
+// empty values are treated as not sent
+func matchOptionalValue(matcher *config.ValueMatcher, value string) bool {
+	if matcher == nil {
+		return true
+	}
+	if value == "" {
+		return matchValue(*matcher, nil, false)
+	}
+	return matchValue(*matcher, []string{value}, true)
+}
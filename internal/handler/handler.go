@@ -1,13 +1,11 @@
 package handler
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
+	"errors"
 	"log"
-	"math/rand"
 	"net/http"
 	"reflect"
+	"strings"
 	"time"
 
 	"mock-harbor/internal/config"
@@ -17,11 +15,34 @@ import (
 type MockHandler struct {
 	Mocks []config.MockConfig
 	DelayConfig *config.DelayConfig
+	// Diagnostics controls the near-miss report returned for unmatched requests
+	Diagnostics config.DiagnosticsConfig
+	// Fallback describes how requests that match no mock are answered
+	Fallback *config.FallbackConfig
+	// Compression controls how response bodies are compressed, unless a mock overrides it
+	Compression *config.CompressionConfig
+	// Next holds the mocks of the usecase that unmatched requests fall through to
+	Next *MockHandler
+	// Usecase names the usecase the mocks were loaded from, for diagnostics
+	Usecase string
+
+	// routes indexes Mocks by method and path, every mock is checked without it
+	routes *config.RouteTable
+	// cursors tracks how far each mock has advanced through its sequence of responses
+	cursors responseCursors
+	// random generates random delays and response picks, seeded from the clock unless SetSeed is called
+	random *randomSource
 }
 
-// NewMockHandler creates a new mock handler with the given mock configurations
+// NewMockHandler creates a new mock handler with the given mock configurations,
+// indexing them by method and path
 func NewMockHandler(mocks []config.MockConfig, delayConfig *config.DelayConfig) *MockHandler {
-	return &MockHandler{Mocks: mocks, DelayConfig: delayConfig}
+	return &MockHandler{
+		Mocks:       mocks,
+		DelayConfig: delayConfig,
+		routes:      config.NewRouteTable(mocks),
+		random:      newRandomSource(time.Now().UnixNano()),
+	}
 }
 
 // ServeHTTP implements the http.Handler interface
@@ -29,9 +50,14 @@ func (h *MockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Received request: %s %s", r.Method, r.URL.Path)
 
 	// Find matching mock
-	mockConfig, found := h.findMatchingMock(r)
+	in := newIncomingRequest(r)
+	mockConfig, params, found := h.resolveMock(in)
 	if !found {
 		log.Printf("No matching mock found for request: %s %s", r.Method, r.URL.Path)
+		if !h.Diagnostics.Disabled {
+			h.writeNearMissReport(w, in)
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("No matching mock found"))
 		return
@@ -46,88 +72,200 @@ func (h *MockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Apply response headers
-	for key, value := range mockConfig.Response.Headers {
-		w.Header().Set(key, value)
+	// Pick the representation the request accepts best, if the response has several
+	if len(mockConfig.Response.Representations) > 0 {
+		addVary(w.Header(), "Accept")
+		mediaTypes := mockConfig.Response.MediaTypes()
+		mediaType, acceptable := negotiateMediaType(r, mediaTypes)
+		if !acceptable {
+			log.Printf("No representation is acceptable for Accept: %s", r.Header.Get("Accept"))
+			w.WriteHeader(http.StatusNotAcceptable)
+			w.Write([]byte("Not acceptable, available representations: " + strings.Join(mediaTypes, ", ")))
+			return
+		}
+		log.Printf("Returning %s representation", mediaType)
+		mockConfig.Response = mockConfig.Response.Representation(mediaType)
+	}
+
+	// Streamed bodies and WebSocket messages are rendered as they are written
+	renderer := newResponseRenderer(in, params, mockConfig.Response)
+	if mockConfig.Response.Stream != nil {
+		h.writeStream(w, r, renderer)
+		return
+	}
+	if mockConfig.WebSocket != nil {
+		h.serveWebSocket(w, r, renderer, mockConfig.WebSocket)
+		return
+	}
+
+	// Render the response body before anything is written, so errors can still be reported
+	responseBody, contentType, err := renderer.renderResponseBody()
+	if errors.Is(err, errBodyFileNotFound) {
+		log.Printf("Error rendering response body: %v", err)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Body file not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error rendering response body: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Apply response headers, rendering templates and filling in captured path parameters
+	if err := renderer.applyHeaders(w); err != nil {
+		log.Printf("Error rendering response header %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Compress the body in an encoding the request accepts, with the mock's settings taking precedence
+	compression := mockConfig.Compression
+	if compression == nil {
+		compression = h.Compression
+	}
+	responseBody, contentType, err = renderer.compressBody(w, compression, responseBody, contentType)
+	var notAcceptable *encodingNotAcceptableError
+	if errors.As(err, &notAcceptable) {
+		log.Printf("Error compressing response body: %v", err)
+		w.WriteHeader(http.StatusNotAcceptable)
+		w.Write([]byte("Not acceptable, available encodings: " + strings.Join(notAcceptable.available, ", ")))
+		return
+	}
+	if err != nil {
+		log.Printf("Error compressing response body: %v", err)
+		w.Header().Del("Content-Encoding")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Default the content type according to the kind of body
+	if contentType != "" && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", contentType)
 	}
 
-	// Set status code
+	// Set status code and write response body
 	w.WriteHeader(mockConfig.Response.StatusCode)
+	w.Write(responseBody)
 
-	// Write response body
-	if mockConfig.Response.Body != nil {
-		responseBody, err := json.Marshal(mockConfig.Response.Body)
-		if err != nil {
-			log.Printf("Error marshalling response body: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	log.Printf("Returned mock response with status: %d", mockConfig.Response.StatusCode)
+}
+
+// findMatchingMock finds the most specific mock configuration that matches the incoming
+// request, ranked by priority and specificity with file order breaking ties.
+// It also returns any path parameters captured by a templated mock path, and
+// sets the mock's Response to the next one in its sequence of responses if it has one.
+func (h *MockHandler) findMatchingMock(in *incomingRequest) (config.MockConfig, map[string]string, bool) {
+	var best config.MockConfig
+	var bestIndex int
+	var bestParams map[string]string
+	var bestSpec config.Specificity
+	found := false
+
+	// Only mocks indexed under the request's method and path can match
+	for _, i := range h.candidates(in.r.Method, in.r.URL.Path) {
+		mock := h.Mocks[i]
+		params, ok := matchRequest(mock.Request, in)
+		if !ok {
+			continue
+		}
+
+		spec := mock.Specificity()
+		if !found || spec.Compare(bestSpec) > 0 {
+			best, bestIndex, bestParams, bestSpec, found = mock, i, params, spec, true
 		}
-		w.Write(responseBody)
 	}
 
-	log.Printf("Returned mock response with status: %d", mockConfig.Response.StatusCode)
+	if found {
+		best.Response = h.selectResponse(bestIndex, best)
+	}
+	return best, bestParams, found
 }
 
-// findMatchingMock tries to find a mock configuration that matches the incoming request
-func (h *MockHandler) findMatchingMock(r *http.Request) (config.MockConfig, bool) {
-	for _, mock := range h.Mocks {
-		// Match path and method
-		if r.URL.Path == mock.Request.Path && r.Method == mock.Request.Method {
-			// If request body is part of the matching criteria
-			if mock.Request.Body != nil {
-				// Read the request body
-				body, err := io.ReadAll(r.Body)
-				if err != nil {
-					log.Printf("Error reading request body: %v", err)
-					continue
-				}
-				// Replace the body for later use
-				r.Body = io.NopCloser(bytes.NewBuffer(body))
-
-				// Try to parse the body as JSON
-				var requestBody map[string]interface{}
-				if err := json.Unmarshal(body, &requestBody); err != nil {
-					log.Printf("Error unmarshalling request body: %v", err)
-					continue
-				}
-
-				// Check if the body matches
-				if !matchesMockBody(requestBody, mock.Request.Body) {
-					continue
-				}
-			}
-			return mock, true
+// matchRequest checks all of the mock's request criteria against the incoming request
+func matchRequest(req config.RequestConfig, in *incomingRequest) (map[string]string, bool) {
+	report := &matchReport{}
+	params := evaluateRequest(req, in, report)
+	return params, len(report.failures) == 0
+}
+
+// evaluateRequest checks the mock's request criteria against the incoming request,
+// recording failed criteria in the report, and returns any captured path parameters
+func evaluateRequest(req config.RequestConfig, in *incomingRequest, report *matchReport) map[string]string {
+	r := in.r
+
+	// Match path and method, either of which may be left to combinators
+	var params map[string]string
+	if req.Path != "" || req.PathRegex != "" {
+		var pathMatches bool
+		params, pathMatches = matchPath(req, r.URL.Path)
+		if !pathMatches {
+			report.fail("path", describePath(req), r.URL.Path)
 		}
 	}
-	return config.MockConfig{}, false
+	if req.Method != "" && r.Method != req.Method {
+		report.fail("method", req.Method, r.Method)
+	}
+	if report.done() {
+		return nil
+	}
+
+	// Match query string parameters, request headers and cookies
+	matchNamedValues("query", req.Query, queryLookup(in.query), report)
+	matchNamedValues("header", req.Headers, headerLookup(r.Header), report)
+	matchNamedValues("cookie", req.Cookies, cookieLookup(in.cookies), report)
+	if report.done() {
+		return nil
+	}
+
+	// If request body is part of the matching criteria
+	if hasBodyCriteria(req) {
+		// Read the request body, once for all mocks
+		body, err := in.Body()
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			report.fail("body", "a readable body", err.Error())
+			return nil
+		}
+
+		// Check if the body matches
+		matchBody(req, in, body, report)
+		if report.done() {
+			return nil
+		}
+	}
+
+	// Match nested anyOf, allOf and not criteria
+	return matchCombinators(req, in, params, report)
 }
 
-// matchesMockBody checks if the received body matches the expected body in the mock
-// This implementation only checks for the specified fields in the mock
-func matchesMockBody(received, expected map[string]interface{}) bool {
-	for key, expectedValue := range expected {
-		receivedValue, exists := received[key]
-		if !exists {
+// matchesMockBody checks if the received body matches the expected body in the mock.
+// Objects only need to contain the fields specified in the mock, arrays are compared
+// according to the array match mode and any other values must be equal.
+func matchesMockBody(received, expected interface{}, arrayMode string) bool {
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		receivedMap, ok := received.(map[string]interface{})
+		if !ok {
 			return false
 		}
-		
-		// For nested objects, recursively check
-		if expectedMap, ok := expectedValue.(map[string]interface{}); ok {
-			if receivedMap, ok := receivedValue.(map[string]interface{}); ok {
-				if !matchesMockBody(receivedMap, expectedMap) {
-					return false
-				}
-				continue
+		for key, expectedField := range expectedValue {
+			receivedField, exists := receivedMap[key]
+			if !exists || !matchesMockBody(receivedField, expectedField, arrayMode) {
+				return false
 			}
-			return false
 		}
-		
-		// For primitive types, do a direct comparison
-		if !reflect.DeepEqual(receivedValue, expectedValue) {
+		return true
+	case []interface{}:
+		receivedArray, ok := received.([]interface{})
+		if !ok {
 			return false
 		}
+		return matchesArray(receivedArray, expectedValue, arrayMode)
+	default:
+		// For primitive types, do a direct comparison
+		return reflect.DeepEqual(received, expected)
 	}
-	return true
 }
 
 // calculateDelay determines the delay duration in milliseconds based on the delay configuration
@@ -143,7 +281,7 @@ func (h *MockHandler) calculateDelay() int {
 
 	// If min and max are specified, use a random value in that range
 	if h.DelayConfig.Min >= 0 && h.DelayConfig.Max > h.DelayConfig.Min {
-		return h.DelayConfig.Min + rand.Intn(h.DelayConfig.Max-h.DelayConfig.Min+1)
+		return h.DelayConfig.Min + h.random.Intn(h.DelayConfig.Max-h.DelayConfig.Min+1)
 	}
 
 	return 0
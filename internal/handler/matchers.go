@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"mock-harbor/internal/config"
+)
+
+// matchValue checks the values sent under one name against a value matcher.
+// present reports whether the name was sent at all.
+func matchValue(matcher config.ValueMatcher, values []string, present bool) bool {
+	if matcher.Absent {
+		return !present
+	}
+	if !present {
+		return false
+	}
+
+	if matcher.Equals != nil && !anyValue(values, func(v string) bool { return v == *matcher.Equals }) {
+		return false
+	}
+
+	if matcher.Contains != "" && !anyValue(values, func(v string) bool { return strings.Contains(v, matcher.Contains) }) {
+		return false
+	}
+
+	if matcher.Regex != "" {
+		// Patterns that failed to compile never match
+		if matcher.Pattern == nil || !anyValue(values, matcher.Pattern.MatchString) {
+			return false
+		}
+	}
+
+	if matcher.Values != nil && !sameValues(values, matcher.Values) {
+		return false
+	}
+
+	return true
+}
+
+// matchNamedValues checks named request values, such as query parameters, against their
+// matchers and records a failure for each one that doesn't match
+func matchNamedValues(kind string, matchers map[string]config.ValueMatcher, lookup func(name string) ([]string, bool), report *matchReport) {
+	for _, name := range sortedNames(matchers) {
+		values, present := lookup(name)
+		if !matchValue(matchers[name], values, present) {
+			report.fail(kind+"."+name, describeValueMatcher(matchers[name]), describeValues(values, present))
+		}
+	}
+}
+
+// queryLookup returns the values of a query parameter
+func queryLookup(query url.Values) func(string) ([]string, bool) {
+	return func(name string) ([]string, bool) {
+		values, present := query[name]
+		return values, present
+	}
+}
+
+// headerLookup returns the values of a header, ignoring the case of its name
+func headerLookup(header http.Header) func(string) ([]string, bool) {
+	return func(name string) ([]string, bool) {
+		values, present := header[http.CanonicalHeaderKey(name)]
+		return values, present
+	}
+}
+
+// cookieLookup returns the values of a cookie
+func cookieLookup(cookies []*http.Cookie) func(string) ([]string, bool) {
+	values := make(map[string][]string)
+	for _, cookie := range cookies {
+		values[cookie.Name] = append(values[cookie.Name], cookie.Value)
+	}
+	return func(name string) ([]string, bool) {
+		cookieValues, present := values[name]
+		return cookieValues, present
+	}
+}
+
+// anyValue reports whether any of the values satisfies the predicate
+func anyValue(values []string, predicate func(string) bool) bool {
+	for _, value := range values {
+		if predicate(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameValues reports whether both slices hold the same values, ignoring order
+func sameValues(received, expected []string) bool {
+	if len(received) != len(expected) {
+		return false
+	}
+
+	a := append([]string(nil), received...)
+	b := append([]string(nil), expected...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is one of the media ranges listed by an Accept header, such as text/*;q=0.5
+type mediaRange struct {
+	mainType string
+	subType  string
+	q        float64
+	position int
+}
+
+// specificity ranks how closely the range names a media type: */* is 0, text/* is 1 and text/csv is 2
+func (m mediaRange) specificity() int {
+	switch {
+	case m.mainType == "*":
+		return 0
+	case m.subType == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matches reports whether the range includes the media type
+func (m mediaRange) matches(mainType, subType string) bool {
+	return (m.mainType == "*" || m.mainType == mainType) && (m.subType == "*" || m.subType == subType)
+}
+
+// parseAccept parses the media ranges of Accept headers, skipping malformed ones.
+// A request without an Accept header, or whose Accept headers list no valid
+// media range, accepts any media type.
+func parseAccept(values []string) []mediaRange {
+	var ranges []mediaRange
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			mediaType, params, err := mime.ParseMediaType(item)
+			if err != nil {
+				continue
+			}
+			mainType, subType, ok := strings.Cut(mediaType, "/")
+			if !ok || (mainType == "*" && subType != "*") {
+				continue
+			}
+
+			q := 1.0
+			if value, exists := params["q"]; exists {
+				if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+					continue
+				}
+			}
+			ranges = append(ranges, mediaRange{mainType: mainType, subType: subType, q: q, position: len(ranges)})
+		}
+	}
+	if len(ranges) == 0 {
+		return []mediaRange{{mainType: "*", subType: "*", q: 1}}
+	}
+	return ranges
+}
+
+// negotiateMediaType picks the media type the request accepts best out of those available,
+// which are weighted by the most specific media range including them. Ties go to the more
+// specific range, then to the range listed first, then to the media type listed first.
+func negotiateMediaType(r *http.Request, available []string) (string, bool) {
+	ranges := parseAccept(r.Header.Values("Accept"))
+
+	var best string
+	var bestRange mediaRange
+	found := false
+	for _, mediaType := range available {
+		mainType, subType, _ := strings.Cut(strings.ToLower(mediaType), "/")
+		if i := strings.IndexByte(subType, ';'); i >= 0 {
+			subType = strings.TrimSpace(subType[:i])
+		}
+
+		// The most specific range including the media type decides its weight
+		var match mediaRange
+		matched := false
+		for _, candidate := range ranges {
+			if candidate.matches(mainType, subType) && (!matched || candidate.specificity() > match.specificity()) {
+				match, matched = candidate, true
+			}
+		}
+		if !matched || match.q == 0 {
+			continue
+		}
+
+		if !found || match.q > bestRange.q ||
+			(match.q == bestRange.q && match.specificity() > bestRange.specificity()) ||
+			(match.q == bestRange.q && match.specificity() == bestRange.specificity() && match.position < bestRange.position) {
+			best, bestRange, found = mediaType, match, true
+		}
+	}
+	return best, found
+}
+
+// addVary adds a request header name to the response's Vary header unless it is already listed
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), name) || strings.TrimSpace(item) == "*" {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
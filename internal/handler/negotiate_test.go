@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	anything := []mediaRange{{mainType: "*", subType: "*", q: 1}}
+
+	tests := []struct {
+		name   string
+		values []string
+		want   []mediaRange
+	}{
+		{"no header", nil, anything},
+		{"empty header", []string{""}, anything},
+		{"single type", []string{"application/json"}, []mediaRange{
+			{mainType: "application", subType: "json", q: 1},
+		}},
+		{"q-values", []string{"text/html;q=0.5, application/xml ; q=0.25,*/*;q=0"}, []mediaRange{
+			{mainType: "text", subType: "html", q: 0.5},
+			{mainType: "application", subType: "xml", q: 0.25, position: 1},
+			{mainType: "*", subType: "*", q: 0, position: 2},
+		}},
+		{"case and parameters", []string{"Text/CSV;charset=utf-8;Q=0.8"}, []mediaRange{
+			{mainType: "text", subType: "csv", q: 0.8},
+		}},
+		{"several headers", []string{"text/*", "image/png;q=1.0"}, []mediaRange{
+			{mainType: "text", subType: "*", q: 1},
+			{mainType: "image", subType: "png", q: 1, position: 1},
+		}},
+		{"malformed ranges are skipped", []string{"text/html;q=2, text/plain;q=abc, text/csv;q=-1, */html, json, , application/json"}, []mediaRange{
+			{mainType: "application", subType: "json", q: 1},
+		}},
+		{"unparseable header", []string{"not a media type; q=x"}, anything},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAccept(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAccept(%q) = %+v, want %+v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	available := []string{"application/json", "application/xml", "text/csv; charset=utf-8"}
+
+	tests := []struct {
+		name   string
+		accept []string
+		want   string // empty when nothing is acceptable
+	}{
+		// Requests that don't say what they accept get the first media type
+		{"no header", nil, "application/json"},
+		{"unparseable header", []string{"garbage"}, "application/json"},
+		{"any", []string{"*/*"}, "application/json"},
+
+		// Quality values
+		{"exact type", []string{"application/xml"}, "application/xml"},
+		{"highest q wins", []string{"application/json;q=0.5, application/xml;q=0.9"}, "application/xml"},
+		{"default q is 1", []string{"application/json;q=0.99, text/csv"}, "text/csv; charset=utf-8"},
+		{"parameters of available types are ignored", []string{"text/csv"}, "text/csv; charset=utf-8"},
+		{"case is ignored", []string{"APPLICATION/XML"}, "application/xml"},
+
+		// Wildcard precedence
+		{"subtype wildcard", []string{"text/*"}, "text/csv; charset=utf-8"},
+		{"specific range outweighs wildcard", []string{"application/*;q=0.9, application/json;q=0.1"}, "application/xml"},
+		{"wildcard fills in for unlisted types", []string{"*/*;q=0.1, application/xml;q=0.5"}, "application/xml"},
+		{"tie goes to the more specific range", []string{"application/*, application/xml"}, "application/xml"},
+		{"tie goes to the range listed first", []string{"application/xml, application/json"}, "application/xml"},
+		{"tie within a range goes to the type listed first", []string{"application/*"}, "application/json"},
+
+		// q=0 excludes a type, even when a wildcard would accept it
+		{"excluded type", []string{"*/*, application/json;q=0"}, "application/xml"},
+		{"excluded subtypes", []string{"application/*;q=0, */*;q=0.1"}, "text/csv; charset=utf-8"},
+		{"everything excluded", []string{"*/*;q=0"}, ""},
+		{"only excluded types listed", []string{"application/json;q=0, application/xml;q=0, text/csv;q=0"}, ""},
+
+		// Types that aren't available
+		{"unavailable type", []string{"image/png"}, ""},
+		{"unavailable wildcard", []string{"image/*, video/*"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, value := range tt.accept {
+				r.Header.Add("Accept", value)
+			}
+
+			got, ok := negotiateMediaType(r, available)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("negotiateMediaType(%q) = %q, %v, want %q", tt.accept, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepresentationsNotAcceptable(t *testing.T) {
+	mocks := loadTestMocks(t, `[{
+		"request": {"method": "GET", "path": "/reports/{id}"},
+		"response": {
+			"statusCode": 200,
+			"representations": {
+				"application/json": {"body": {"id": "{id}"}},
+				"text/csv": {"bodyText": "id\n{id}\n"}
+			}
+		}
+	}]`)
+	h := NewMockHandler(mocks, nil)
+
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"text/csv", http.StatusOK, "text/csv", "id\n7\n"},
+		{"application/*", http.StatusOK, "application/json", `{"id":"7"}`},
+		{"image/png", http.StatusNotAcceptable, "", "Not acceptable, available representations: application/json, text/csv"},
+		{"*/*;q=0", http.StatusNotAcceptable, "", "Not acceptable, available representations: application/json, text/csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/reports/7", nil)
+			r.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Content-Type"); tt.contentType != "" && got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %q, want %q", got, "Accept")
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"regexp"
+
+	"mock-harbor/internal/config"
+)
+
+// paramPlaceholder matches {name} placeholders in response values
+var paramPlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// matchPath checks the request path against the mock path or path regex,
+// capturing the values of any template parameters or named groups
+func matchPath(req config.RequestConfig, path string) (map[string]string, bool) {
+	if req.PathRegex != "" {
+		return matchPathRegex(req.PathPattern, path)
+	}
+
+	if req.PathSegments == nil {
+		return nil, path == req.Path
+	}
+	return config.MatchPathSegments(req.PathSegments, path)
+}
+
+// matchPathRegex checks the request path against a compiled path regex,
+// capturing the values of any named groups
+func matchPathRegex(pattern *regexp.Regexp, path string) (map[string]string, bool) {
+	// Patterns that failed to compile never match
+	if pattern == nil {
+		return nil, false
+	}
+
+	match := pattern.FindStringSubmatch(path)
+	if match == nil {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, name := range pattern.SubexpNames() {
+		if name != "" {
+			params[name] = match[i]
+		}
+	}
+	return params, true
+}
+
+// substituteParams replaces {name} placeholders in a string with captured path parameters
+func substituteParams(value string, params map[string]string) string {
+	if len(params) == 0 {
+		return value
+	}
+	return paramPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+		if captured, ok := params[placeholder[1:len(placeholder)-1]]; ok {
+			return captured
+		}
+		return placeholder
+	})
+}
+
+// substituteParamsInValue walks a decoded JSON value and replaces {name}
+// placeholders in all string values, returning a copy
+func substituteParamsInValue(value interface{}, params map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return substituteParams(v, params)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = substituteParamsInValue(item, params)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = substituteParamsInValue(item, params)
+		}
+		return result
+	default:
+		return value
+	}
+}
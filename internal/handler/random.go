@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"math/rand"
+	"sync"
+
+	"mock-harbor/internal/config"
+)
+
+// randomSource generates the random delays and response picks of a handler.
+// It is safe for concurrent use.
+type randomSource struct {
+	mutex sync.Mutex
+	rng   *rand.Rand
+}
+
+// newRandomSource creates a random source that generates the same numbers for the same seed
+func newRandomSource(seed int64) *randomSource {
+	return &randomSource{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Intn returns a random number between 0 and n-1
+func (s *randomSource) Intn(n int) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.rng.Intn(n)
+}
+
+// SetSeed makes the handler's random delays and response picks reproducible
+func (h *MockHandler) SetSeed(seed int64) {
+	h.random = newRandomSource(seed)
+}
+
+// pickWeighted picks a response at random, each with a chance proportional to its weight
+func pickWeighted(responses []config.ResponseConfig, random *randomSource) int {
+	total := 0
+	for _, response := range responses {
+		total += responseWeight(response)
+	}
+
+	n := random.Intn(total)
+	for i, response := range responses {
+		n -= responseWeight(response)
+		if n < 0 {
+			return i
+		}
+	}
+	return len(responses) - 1
+}
+
+// responseWeight returns the weight of a response, defaulting to 1
+func responseWeight(response config.ResponseConfig) int {
+	if response.Weight == 0 {
+		return 1
+	}
+	return response.Weight
+}
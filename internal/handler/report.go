@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"mock-harbor/internal/config"
+)
+
+// maxReportedValueLength truncates request values quoted in mismatch reports
+const maxReportedValueLength = 200
+
+// mismatch describes a request criterion that a mock failed on
+type mismatch struct {
+	Criterion string `json:"criterion"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+}
+
+// String formats the mismatch for logging
+func (m mismatch) String() string {
+	return fmt.Sprintf("%s (expected %s, got %s)", m.Criterion, m.Expected, m.Actual)
+}
+
+// matchReport records the criteria a request failed while being checked against a mock.
+// Unless exhaustive, checking stops after the first group of criteria that fails.
+type matchReport struct {
+	exhaustive bool
+	failures   []mismatch
+}
+
+// fail records a failed criterion
+func (m *matchReport) fail(criterion, expected, actual string) {
+	m.failures = append(m.failures, mismatch{Criterion: criterion, Expected: expected, Actual: actual})
+}
+
+// done reports whether checking can stop
+func (m *matchReport) done() bool {
+	return !m.exhaustive && len(m.failures) > 0
+}
+
+// failed reports whether the given criterion failed
+func (m *matchReport) failed(criterion string) bool {
+	for _, failure := range m.failures {
+		if failure.Criterion == criterion {
+			return true
+		}
+	}
+	return false
+}
+
+// describeValueMatcher formats a value matcher for mismatch reports
+func describeValueMatcher(matcher config.ValueMatcher) string {
+	if matcher.Absent {
+		return "absent"
+	}
+
+	var parts []string
+	if matcher.Equals != nil {
+		parts = append(parts, fmt.Sprintf("equals %q", *matcher.Equals))
+	}
+	if matcher.Contains != "" {
+		parts = append(parts, fmt.Sprintf("contains %q", matcher.Contains))
+	}
+	if matcher.Regex != "" {
+		parts = append(parts, fmt.Sprintf("matches /%s/", matcher.Regex))
+	}
+	if matcher.Values != nil {
+		parts = append(parts, fmt.Sprintf("values %q", matcher.Values))
+	}
+	if len(parts) == 0 {
+		return "present"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// describeValues formats the values sent under one name for mismatch reports
+func describeValues(values []string, present bool) string {
+	if !present {
+		return "absent"
+	}
+	if len(values) == 1 {
+		return truncate(fmt.Sprintf("%q", values[0]))
+	}
+	return truncate(fmt.Sprintf("%q", values))
+}
+
+// describeJSON formats a decoded JSON value for mismatch reports
+func describeJSON(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return truncate(string(encoded))
+}
+
+// describePath formats the mock's path criterion for mismatch reports
+func describePath(req config.RequestConfig) string {
+	switch {
+	case req.PathRegex != "":
+		return "/" + req.PathRegex + "/"
+	case req.Path == "":
+		return "*"
+	default:
+		return req.Path
+	}
+}
+
+// describeMock formats a short label identifying the mock
+func describeMock(mock config.MockConfig) string {
+	method := mock.Request.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + describePath(mock.Request)
+}
+
+// truncate shortens long request values in reports
+func truncate(value string) string {
+	if len(value) <= maxReportedValueLength {
+		return value
+	}
+	return value[:maxReportedValueLength] + "..."
+}
+
+// sortedNames returns the names of a matcher map in a stable order
+func sortedNames(matchers map[string]config.ValueMatcher) []string {
+	names := make([]string, 0, len(matchers))
+	for name := range matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"mock-harbor/internal/xpath"
+)
+
+// incomingRequest wraps a request being matched, parsing its query string, cookies
+// and body at most once however many mocks it is checked against.
+// The body is only read and decoded when a mock has body criteria.
+type incomingRequest struct {
+	r *http.Request
+
+	query   url.Values
+	cookies []*http.Cookie
+
+	body     []byte
+	bodyErr  error
+	bodyRead bool
+
+	json       interface{}
+	jsonErr    error
+	jsonParsed bool
+
+	xml       *xpath.Document
+	xmlErr    error
+	xmlParsed bool
+
+	form       *formData
+	formErr    error
+	formParsed bool
+}
+
+// newIncomingRequest prepares a request for matching
+func newIncomingRequest(r *http.Request) *incomingRequest {
+	return &incomingRequest{
+		r:       r,
+		query:   r.URL.Query(),
+		cookies: r.Cookies(),
+	}
+}
+
+// Body reads the raw request body, replacing it on the request for later use
+func (in *incomingRequest) Body() ([]byte, error) {
+	if !in.bodyRead {
+		in.bodyRead = true
+		in.body, in.bodyErr = io.ReadAll(in.r.Body)
+		in.r.Body = io.NopCloser(bytes.NewReader(in.body))
+	}
+	return in.body, in.bodyErr
+}
+
+// JSON decodes the request body as JSON
+func (in *incomingRequest) JSON() (interface{}, error) {
+	if !in.jsonParsed {
+		in.jsonParsed = true
+		body, _ := in.Body()
+		in.jsonErr = json.Unmarshal(body, &in.json)
+	}
+	return in.json, in.jsonErr
+}
+
+// XML parses the request body as an XML document
+func (in *incomingRequest) XML() (*xpath.Document, error) {
+	if !in.xmlParsed {
+		in.xmlParsed = true
+		body, _ := in.Body()
+		in.xml, in.xmlErr = xpath.Parse(body)
+	}
+	return in.xml, in.xmlErr
+}
+
+// Form decodes the request body as a urlencoded or multipart form
+func (in *incomingRequest) Form() (*formData, error) {
+	if !in.formParsed {
+		in.formParsed = true
+		body, _ := in.Body()
+		in.form, in.formErr = parseFormBody(in.r.Header.Get("Content-Type"), body)
+	}
+	return in.form, in.formErr
+}
@@ -0,0 +1,13 @@
+package handler
+
+// candidates returns the positions of the mocks that may match the method and path
+func (h *MockHandler) candidates(method, path string) []int {
+	if h.routes == nil {
+		indices := make([]int, len(h.Mocks))
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+	return h.routes.Lookup(method, path)
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"mock-harbor/internal/config"
+)
+
+// loadTestMocks loads mocks from JSON the way they are loaded from a usecase directory
+func loadTestMocks(t *testing.T, mocksJSON string) []config.MockConfig {
+	t.Helper()
+	dir := t.TempDir()
+	usecaseDir := filepath.Join(dir, "svc", "usecases", "main")
+	if err := os.MkdirAll(usecaseDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(usecaseDir, "all.json"), []byte(mocksJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mocks, err := config.LoadMockConfigs(dir, "svc", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mocks
+}
+
+// Each mock answers with its own status code, 200 plus its position
+const routeTestMocks = `[
+	{"request": {"method": "GET", "path": "/users/{id}"}, "response": {"statusCode": 200}},
+	{"request": {"method": "GET", "path": "/users/me"}, "response": {"statusCode": 201}},
+	{"request": {"method": "GET", "path": "/files/{path...}"}, "response": {"statusCode": 202}},
+	{"request": {"method": "GET", "pathRegex": "^/reports/[0-9]+$"}, "response": {"statusCode": 203}},
+	{"request": {"anyOf": [{"method": "GET", "path": "/a"}, {"path": "/b/{x}"}]}, "response": {"statusCode": 204}},
+	{"request": {"method": "GET", "allOf": [{"path": "/users/{id}"}], "query": {"v": {"equals": "2"}}}, "response": {"statusCode": 205}},
+	{"request": {"path": "/health"}, "response": {"statusCode": 206}},
+	{"request": {"method": "GET", "path": "/users/{id}"}, "response": {"statusCode": 207}},
+	{"request": {"method": "POST", "path": "/users"}, "response": {"statusCode": 208}},
+	{"request": {"method": "GET", "path": "/files/static/logo.png"}, "response": {"statusCode": 209}},
+	{"request": {"method": "GET", "path": "/users/{id}/orders/{orderId}"}, "response": {"statusCode": 210}},
+	{"request": {"method": "GET", "path": "/users/{id}", "headers": {"X-Beta": {"equals": "1"}}}, "priority": 1, "response": {"statusCode": 211}}
+]`
+
+func TestCandidatesMatchLinearScan(t *testing.T) {
+	mocks := loadTestMocks(t, routeTestMocks)
+	indexed := NewMockHandler(mocks, nil)
+	linear := NewMockHandler(mocks, nil)
+	linear.routes = nil
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		header     string
+		wantStatus int // 0 when no mock matches
+		wantParams map[string]string
+	}{
+		{"template", "GET", "/users/5", "", 200, map[string]string{"id": "5"}},
+		{"exact path beats template", "GET", "/users/me", "", 201, nil},
+		{"priority beats exact path", "GET", "/users/me", "1", 211, map[string]string{"id": "me"}},
+		{"template with empty segment", "GET", "/users/", "", 0, nil},
+		{"nested template", "GET", "/users/5/orders/9", "", 210, map[string]string{"id": "5", "orderId": "9"}},
+		{"nested template with empty segment", "GET", "/users//orders/9", "", 0, nil},
+		{"template beats path-less allOf", "GET", "/users/5?v=2", "", 200, map[string]string{"id": "5"}},
+		{"catch-all", "GET", "/files/a/b/c", "", 202, map[string]string{"path": "a/b/c"}},
+		{"catch-all needs a segment", "GET", "/files", "", 0, nil},
+		{"exact path beats catch-all", "GET", "/files/static/logo.png", "", 209, nil},
+		{"regex", "GET", "/reports/12", "", 203, nil},
+		{"regex mismatch", "GET", "/reports/x", "", 0, nil},
+		{"regex wrong method", "POST", "/reports/12", "", 0, nil},
+		{"path-less anyOf", "GET", "/a", "", 204, nil},
+		{"path-less anyOf wrong method", "POST", "/a", "", 0, nil},
+		{"path-less anyOf any method", "DELETE", "/b/1", "", 204, map[string]string{"x": "1"}},
+		{"any method", "PATCH", "/health", "", 206, nil},
+		{"method", "POST", "/users", "", 208, nil},
+		{"wrong method", "DELETE", "/users", "", 0, nil},
+		{"root", "GET", "/", "", 0, nil},
+		{"unknown path", "GET", "/nope", "", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("X-Beta", tt.header)
+			}
+			in := newIncomingRequest(r)
+
+			// The candidates include every mock a linear scan matches, in file order
+			candidates := indexed.candidates(r.Method, r.URL.Path)
+			if !sort.IntsAreSorted(candidates) {
+				t.Errorf("candidates %v are not in file order", candidates)
+			}
+			for i, mock := range mocks {
+				if _, ok := matchRequest(mock.Request, in); ok && !containsInt(candidates, i) {
+					t.Errorf("mock %d matches but is missing from candidates %v", i, candidates)
+				}
+			}
+
+			for _, h := range []*MockHandler{indexed, linear} {
+				mock, params, found := h.findMatchingMock(in)
+				status := 0
+				if found {
+					status = mock.Response.StatusCode
+				}
+				if status != tt.wantStatus {
+					t.Errorf("indexed=%v: matched status %d, want %d", h.routes != nil, status, tt.wantStatus)
+				}
+				if len(params) != 0 || len(tt.wantParams) != 0 {
+					if !reflect.DeepEqual(params, tt.wantParams) {
+						t.Errorf("indexed=%v: params %v, want %v", h.routes != nil, params, tt.wantParams)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRouteTableKeepsFileOrderForEqualMocks(t *testing.T) {
+	mocks := loadTestMocks(t, routeTestMocks)
+	got := config.NewRouteTable(mocks).Lookup("GET", "/users/5")
+	want := []int{0, 3, 4, 5, 7, 11}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(GET /users/5) = %v, want %v", got, want)
+	}
+
+	// Of the two equally specific templates the first in the file wins
+	mock, _, found := NewMockHandler(mocks, nil).findMatchingMock(newIncomingRequest(httptest.NewRequest("GET", "/users/5", nil)))
+	if !found || mock.Response.StatusCode != 200 {
+		t.Errorf("matched %+v, want the first /users/{id} mock", mock.Response)
+	}
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"log"
+	"sync"
+
+	"mock-harbor/internal/config"
+)
+
+// responseCursors counts the calls answered by each mock with a sequence of responses,
+// keyed by the mock's position in the handler. It is safe for concurrent use.
+type responseCursors struct {
+	mutex sync.Mutex
+	calls map[int]int
+}
+
+// next returns how many earlier calls the mock has answered and counts this one
+func (c *responseCursors) next(mock int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.calls == nil {
+		c.calls = make(map[int]int)
+	}
+	call := c.calls[mock]
+	c.calls[mock] = call + 1
+	return call
+}
+
+// reset starts every sequence over from its first response
+func (c *responseCursors) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.calls = nil
+}
+
+// ResetSequences starts the response sequences of every mock over, including
+// those of the usecase unmatched requests fall through to
+func (h *MockHandler) ResetSequences() {
+	h.cursors.reset()
+	if h.Next != nil {
+		h.Next.ResetSequences()
+	}
+}
+
+// selectResponse returns the response for this call to the mock at the given position,
+// advancing its sequence if it has one
+func (h *MockHandler) selectResponse(index int, mock config.MockConfig) config.ResponseConfig {
+	if !mock.HasResponses() {
+		return mock.Response
+	}
+
+	count := len(mock.Responses)
+	var selected int
+	switch mock.Mode {
+	case config.ModeCycle:
+		selected = h.cursors.next(index) % count
+	case config.ModeRandom:
+		selected = pickWeighted(mock.Responses, h.random)
+		log.Printf("Picked response variant %d of %d with weight %d", selected+1, count, responseWeight(mock.Responses[selected]))
+		return mock.Responses[selected]
+	default:
+		selected = h.cursors.next(index)
+		if selected >= count {
+			selected = count - 1
+		}
+	}
+
+	log.Printf("Returning response %d of %d in sequence", selected+1, count)
+	return mock.Responses[selected]
+}
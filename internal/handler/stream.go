@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mock-harbor/internal/config"
+	"mock-harbor/internal/templating"
+)
+
+// errClientGone reports that the client disconnected while a stream was being written
+var errClientGone = errors.New("client disconnected")
+
+// waitFor pauses for the delay, returning early if the context is done
+func waitFor(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		if ctx.Err() != nil {
+			return errClientGone
+		}
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return errClientGone
+	}
+}
+
+// writeStream writes a streamed response, flushing each chunk or event to the client
+// as it is written, until the stream ends or the client disconnects
+func (h *MockHandler) writeStream(w http.ResponseWriter, r *http.Request, renderer *responseRenderer) {
+	response := renderer.response
+	stream := response.Stream
+
+	if err := renderer.applyHeaders(w); err != nil {
+		log.Printf("Error rendering response header %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if stream.IsSSE() {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		if w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+	} else if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Printf("Response writer can't flush, the streamed response may be buffered")
+	}
+	sw := &streamWriter{w: w, flusher: flusher, ctx: r.Context()}
+	w.WriteHeader(response.StatusCode)
+	sw.flush()
+
+	err := streamItems(sw, renderer, stream)
+	if err == nil && stream.IsSSE() && stream.KeepAlive > 0 {
+		err = sw.keepAlive(time.Duration(stream.KeepAlive) * time.Millisecond)
+	}
+
+	switch {
+	case errors.Is(err, errClientGone):
+		log.Printf("Stopped streaming response: %v", err)
+	case err != nil:
+		log.Printf("Error streaming response: %v", err)
+	default:
+		log.Printf("Returned streamed response with status: %d", response.StatusCode)
+	}
+}
+
+// streamItems writes the chunks or events of the stream as many times as it repeats,
+// exposing the repetition to templates as .Index
+func streamItems(sw *streamWriter, renderer *responseRenderer, stream *config.StreamConfig) error {
+	for repetition := 0; stream.Repeat < 0 || repetition < max(stream.Repeat, 1); repetition++ {
+		data := *renderer.templateData()
+		data.Index = repetition
+
+		for _, chunk := range stream.Chunks {
+			if err := sw.wait(time.Duration(chunk.Delay) * time.Millisecond); err != nil {
+				return err
+			}
+			rendered, err := renderer.renderStringFor(chunk.Data, chunk.DataTemplate, &data)
+			if err != nil {
+				return err
+			}
+			if err := sw.write(rendered); err != nil {
+				return err
+			}
+		}
+
+		for _, event := range stream.Events {
+			if err := sw.wait(time.Duration(event.Delay) * time.Millisecond); err != nil {
+				return err
+			}
+			rendered, err := renderEvent(renderer, event, &data)
+			if err != nil {
+				return err
+			}
+			if err := sw.write(rendered); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderEvent renders a Server-Sent Event in the text/event-stream format
+func renderEvent(renderer *responseRenderer, event config.StreamEvent, data *templating.Data) (string, error) {
+	var sb strings.Builder
+	if event.Event != "" {
+		sb.WriteString("event: " + event.Event + "\n")
+	}
+	if event.ID != "" {
+		id, err := renderer.renderStringFor(event.ID, event.IDTemplate, data)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("id: " + id + "\n")
+	}
+	if event.Retry > 0 {
+		sb.WriteString("retry: " + strconv.Itoa(event.Retry) + "\n")
+	}
+
+	if event.Data != nil {
+		// Events that weren't loaded from configuration have no parsed templates
+		value := event.DataTemplate
+		if value == nil {
+			value = event.Data
+		}
+		rendered, err := renderer.renderValue(value, data)
+		if err != nil {
+			return "", err
+		}
+
+		text, ok := rendered.(string)
+		if !ok {
+			encoded, err := json.Marshal(rendered)
+			if err != nil {
+				return "", err
+			}
+			text = string(encoded)
+		}
+		for _, line := range strings.Split(text, "\n") {
+			sb.WriteString("data: " + line + "\n")
+		}
+	}
+
+	sb.WriteString("\n")
+	return sb.String(), nil
+}
+
+// streamWriter writes and flushes the parts of a streamed response, stopping once the client disconnects
+type streamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ctx     context.Context
+}
+
+// wait pauses the stream, returning early if the client disconnects
+func (sw *streamWriter) wait(delay time.Duration) error {
+	return waitFor(sw.ctx, delay)
+}
+
+// write sends part of the stream to the client
+func (sw *streamWriter) write(text string) error {
+	if sw.ctx.Err() != nil {
+		return errClientGone
+	}
+	if _, err := sw.w.Write([]byte(text)); err != nil {
+		return fmt.Errorf("%w: %v", errClientGone, err)
+	}
+	sw.flush()
+	return nil
+}
+
+// flush sends buffered output to the client, if the response writer supports it
+func (sw *streamWriter) flush() {
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+}
+
+// keepAlive writes an SSE comment at every interval until the client disconnects
+func (sw *streamWriter) keepAlive(interval time.Duration) error {
+	for {
+		if err := sw.wait(interval); err != nil {
+			return err
+		}
+		if err := sw.write(": keepalive\n\n"); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"mock-harbor/internal/config"
+	"mock-harbor/internal/templating"
+)
+
+// errBodyFileNotFound reports a body file that doesn't exist, such as a templated
+// file name rendered for an unknown ID
+var errBodyFileNotFound = errors.New("body file not found")
+
+// responseRenderer renders the templates of a response for the matched request,
+// describing the request to templates only once they need it
+type responseRenderer struct {
+	in       *incomingRequest
+	params   map[string]string
+	response config.ResponseConfig
+	data     *templating.Data
+	executor *templating.Executor
+	// bodyFile is the path of the body file rendered, if any
+	bodyFile string
+}
+
+// newResponseRenderer creates a renderer for the response to a request and its captured path parameters
+func newResponseRenderer(in *incomingRequest, params map[string]string, response config.ResponseConfig) *responseRenderer {
+	return &responseRenderer{in: in, params: params, response: response}
+}
+
+// renderString renders a templated string, or fills in {name} placeholders if it isn't one
+func (rr *responseRenderer) renderString(value string, tmpl *template.Template) (string, error) {
+	if tmpl == nil {
+		return substituteParams(value, rr.params), nil
+	}
+	return rr.execute(tmpl, rr.templateData())
+}
+
+// renderStringFor renders a templated string with the given template data, such as that of
+// one repetition of a stream, or fills in {name} placeholders if it isn't one
+func (rr *responseRenderer) renderStringFor(value string, tmpl *template.Template, data *templating.Data) (string, error) {
+	if tmpl == nil {
+		return substituteParams(value, rr.params), nil
+	}
+	return rr.execute(tmpl, data)
+}
+
+// renderResponseBody renders the response body, returning it with the content type
+// it is served with unless the response configures one
+func (rr *responseRenderer) renderResponseBody() ([]byte, string, error) {
+	response := rr.response
+	switch {
+	case response.BodyBase64 != "":
+		// Responses that weren't loaded from configuration have no decoded content
+		content := response.BodyBytes
+		if content == nil {
+			var err error
+			if content, err = base64.StdEncoding.DecodeString(response.BodyBase64); err != nil {
+				return nil, "", err
+			}
+		}
+		return content, http.DetectContentType(content), nil
+	case response.BodyText != "":
+		text, err := rr.renderString(response.BodyText, response.BodyTextTemplate)
+		if err != nil {
+			return nil, "", err
+		}
+		return []byte(text), http.DetectContentType([]byte(text)), nil
+	case response.BodyFile != "":
+		return rr.renderBodyFile()
+	case response.BodyXML != "":
+		document, err := rr.renderString(response.BodyXML, response.BodyXMLTemplate)
+		if err != nil {
+			return nil, "", err
+		}
+		return []byte(document), "application/xml; charset=utf-8", nil
+	case response.Body != nil:
+		body, err := rr.renderBody()
+		if err != nil {
+			return nil, "", err
+		}
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, "", err
+		}
+		return encoded, "application/json", nil
+	default:
+		return nil, "", nil
+	}
+}
+
+// applyHeaders renders the response headers onto the response writer
+func (rr *responseRenderer) applyHeaders(w http.ResponseWriter) error {
+	for _, key := range sortedHeaderNames(rr.response.Headers) {
+		rendered, err := rr.renderString(rr.response.Headers[key], rr.response.HeaderTemplates[key])
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		w.Header().Set(key, rendered)
+	}
+	return nil
+}
+
+// renderBodyFile reads the response's body file, rendering its name if templated,
+// and returns its content with the content type for its extension
+func (rr *responseRenderer) renderBodyFile() ([]byte, string, error) {
+	response := rr.response
+	path, content := response.BodyFilePath, response.BodyFileContent
+	if response.BodyFileTemplate != nil {
+		name, err := rr.execute(response.BodyFileTemplate, rr.templateData())
+		if err != nil {
+			return nil, "", err
+		}
+
+		// Names built from request data must stay inside the usecase directory
+		var inside bool
+		if path, inside = config.JoinBodyFile(response.BodyFileDir, name); !inside {
+			return nil, "", fmt.Errorf("%w: '%s' is outside the usecase directory", errBodyFileNotFound, name)
+		}
+	} else if path == "" {
+		// Responses that weren't loaded from configuration have no resolved path
+		path = response.BodyFile
+	}
+
+	if content == nil {
+		var err error
+		if content, err = os.ReadFile(path); err != nil {
+			if os.IsNotExist(err) {
+				return nil, "", fmt.Errorf("%w: %s", errBodyFileNotFound, path)
+			}
+			return nil, "", err
+		}
+	}
+
+	rr.bodyFile = path
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	return content, contentType, nil
+}
+
+// renderBody renders the templated strings of a JSON response body, returning a copy
+func (rr *responseRenderer) renderBody() (interface{}, error) {
+	// Responses that weren't loaded from configuration have no parsed templates
+	if rr.response.BodyTemplate == nil {
+		return substituteParamsInValue(rr.response.Body, rr.params), nil
+	}
+	return rr.renderValue(rr.response.BodyTemplate, rr.templateData())
+}
+
+// renderValue walks a compiled body value, rendering its templates and filling in
+// {name} placeholders in the other strings. Templates that are a single action are
+// decoded as JSON, so "{{.Body.id}}" keeps a numeric id a number. Object keys are
+// rendered in sorted order so that seeded fake data is reproducible.
+func (rr *responseRenderer) renderValue(value interface{}, data *templating.Data) (interface{}, error) {
+	switch v := value.(type) {
+	case *template.Template:
+		rendered, err := rr.execute(v, data)
+		if err != nil || !templating.IsSingleAction(v) {
+			return rendered, err
+		}
+		return decodeRendered(rendered), nil
+	case *config.TemplateRepeat:
+		return rr.renderRepeat(v, data)
+	case string:
+		return substituteParams(v, rr.params), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		result := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			rendered, err := rr.renderValue(v[key], data)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = rendered
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := rr.renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = rendered
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// decodeRendered decodes the output of a single-action template as a JSON value,
+// keeping it as a string if it isn't exactly one. Numbers keep their precision.
+func decodeRendered(rendered string) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(rendered))
+	decoder.UseNumber()
+	var value interface{}
+	if decoder.Decode(&value) != nil || decoder.Decode(new(interface{})) != io.EOF {
+		return rendered
+	}
+	return value
+}
+
+// renderRepeat renders a $repeat directive into an array, exposing each item's position as .Index
+func (rr *responseRenderer) renderRepeat(repeat *config.TemplateRepeat, data *templating.Data) ([]interface{}, error) {
+	var count int
+	switch c := repeat.Count.(type) {
+	case int:
+		count = c
+	case *template.Template:
+		rendered, err := rr.execute(c, data)
+		if err != nil {
+			return nil, err
+		}
+		count, err = strconv.Atoi(strings.TrimSpace(rendered))
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%s rendered '%s', which is not a non-negative integer", config.RepeatKey, rendered)
+		}
+		if err := templating.CheckRepeat(count); err != nil {
+			return nil, fmt.Errorf("%s: %w", config.RepeatKey, err)
+		}
+	}
+
+	result := make([]interface{}, count)
+	for i := range result {
+		itemData := *data
+		itemData.Index = i
+		rendered, err := rr.renderValue(repeat.Item, &itemData)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = rendered
+	}
+	return result, nil
+}
+
+// execute renders a template, drawing fake data from a generator created for the
+// response on first use and seeded from the response's seed key if it has one
+func (rr *responseRenderer) execute(tmpl *template.Template, data *templating.Data) (string, error) {
+	if rr.executor == nil {
+		key := ""
+		if rr.response.SeedTemplate != nil {
+			// Fake data in the seed key itself is random
+			rendered, err := templating.NewExecutor(templating.NewFaker("")).Execute(rr.response.SeedTemplate, data)
+			if err != nil {
+				return "", fmt.Errorf("rendering seed: %w", err)
+			}
+			key = rendered
+		}
+		rr.executor = templating.NewExecutor(templating.NewFaker(key))
+	}
+	return rr.executor.Execute(tmpl, data)
+}
+
+// templateData describes the request to templates, decoding its JSON body if any
+func (rr *responseRenderer) templateData() *templating.Data {
+	if rr.data != nil {
+		return rr.data
+	}
+
+	r := rr.in.r
+	data := &templating.Data{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Params:  rr.params,
+		Query:   firstValues(rr.in.query),
+		Headers: firstValues(r.Header),
+		Cookies: make(map[string]string),
+	}
+	if data.Params == nil {
+		data.Params = make(map[string]string)
+	}
+	for _, cookie := range rr.in.cookies {
+		if _, exists := data.Cookies[cookie.Name]; !exists {
+			data.Cookies[cookie.Name] = cookie.Value
+		}
+	}
+	if body, err := rr.in.JSON(); err == nil {
+		data.Body = body
+	}
+
+	rr.data = data
+	return data
+}
+
+// firstValues returns the first value of each name in a query string or header
+func firstValues[T ~map[string][]string](values T) map[string]string {
+	first := make(map[string]string, len(values))
+	for name, list := range values {
+		if len(list) > 0 {
+			first[name] = list[0]
+		}
+	}
+	return first
+}
+
+// sortedHeaderNames returns the names of the response headers in the order they are rendered
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"mock-harbor/internal/config"
+	"mock-harbor/internal/templating"
+	"mock-harbor/internal/websocket"
+)
+
+// serveWebSocket upgrades the request and plays the mock's scripted conversation
+// until either side closes the connection
+func (h *MockHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, renderer *responseRenderer, ws *config.WebSocketConfig) {
+	if !websocket.IsUpgrade(r) {
+		log.Printf("WebSocket endpoint received a request without an upgrade: %s %s", r.Method, r.URL.Path)
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.WriteHeader(http.StatusUpgradeRequired)
+		w.Write([]byte("WebSocket upgrade required"))
+		return
+	}
+
+	// Describe the request to templates before its connection is taken over
+	data := renderer.templateData()
+
+	conn, err := websocket.Upgrade(w, r, ws.Protocol)
+	if err != nil {
+		log.Printf("Error upgrading to a WebSocket connection: %v", err)
+		if errors.Is(err, websocket.ErrNotUpgrade) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+		}
+		return
+	}
+	log.Printf("WebSocket connection opened: %s", r.URL.Path)
+
+	// The request's context outlives the hijacked connection's handshake and is
+	// canceled when the server stops, which closes the connection
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close(websocket.CloseGoingAway, "")
+	}()
+	session := &webSocketSession{conn: conn, renderer: renderer, data: data, ctx: ctx}
+
+	go session.send(ws.OnOpen, data)
+	for _, timer := range ws.Timers {
+		go session.runTimer(timer)
+	}
+	if ws.Close != nil {
+		go session.close(*ws.Close)
+	}
+	replies := make(chan webSocketReply, replyQueueSize)
+	go session.reply(replies)
+	session.receive(ws.Rules, replies)
+}
+
+// replyQueueSize is how many of the client's messages may wait for their replies
+// before reading further messages pauses
+const replyQueueSize = 64
+
+// webSocketReply is the reply of a rule to one of the client's messages
+type webSocketReply struct {
+	rule config.WebSocketRule
+	data templating.Data
+}
+
+// webSocketSession plays a scripted conversation over one WebSocket connection.
+// Messages are sent from several goroutines, which stop once the connection is closed.
+type webSocketSession struct {
+	conn     *websocket.Conn
+	renderer *responseRenderer
+	data     *templating.Data
+	ctx      context.Context
+
+	mutex sync.Mutex // Serializes rendering, which shares the fake data generator
+}
+
+// receive reads the client's messages, queuing the reply of the first matching rule,
+// until the connection closes
+func (s *webSocketSession) receive(rules []config.WebSocketRule, replies chan<- webSocketReply) {
+	defer close(replies)
+	for {
+		opcode, payload, err := s.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			switch {
+			case errors.As(err, &closeErr):
+				log.Printf("WebSocket connection closed by client: %v", closeErr)
+			case errors.Is(err, net.ErrClosed):
+				log.Printf("WebSocket connection closed")
+			default:
+				log.Printf("WebSocket connection closed: %v", err)
+			}
+			s.conn.Close(websocket.CloseGoingAway, "")
+			return
+		}
+
+		// Text messages are decoded if they are JSON, so rules and templates can use their fields
+		var message interface{}
+		if opcode == websocket.OpText {
+			message = string(payload)
+			var decoded interface{}
+			if json.Unmarshal(payload, &decoded) == nil {
+				message = decoded
+			}
+		}
+
+		rule, found := matchWebSocketRule(rules, payload, message)
+		if !found {
+			log.Printf("No rule matches WebSocket message: %s", truncate(string(payload)))
+			continue
+		}
+
+		data := *s.data
+		data.Message = message
+		select {
+		case replies <- webSocketReply{rule: rule, data: data}:
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+// reply sends the queued replies one after another, so they reach the client
+// in the order of the messages they answer
+func (s *webSocketSession) reply(replies <-chan webSocketReply) {
+	for r := range replies {
+		if s.send(r.rule.Reply, &r.data) && r.rule.Close != nil {
+			s.close(*r.rule.Close)
+		}
+	}
+}
+
+// matchWebSocketRule finds the first rule matching a message from the client
+func matchWebSocketRule(rules []config.WebSocketRule, payload []byte, message interface{}) (config.WebSocketRule, bool) {
+	for _, rule := range rules {
+		if rule.JSON != nil && (message == nil || !matchesMockBody(message, rule.JSON, "")) {
+			continue
+		}
+		if rule.Regex != "" && (rule.Pattern == nil || !rule.Pattern.Match(payload)) {
+			continue
+		}
+		return rule, true
+	}
+	return config.WebSocketRule{}, false
+}
+
+// send writes messages to the client in order, reporting whether all of them were sent
+func (s *webSocketSession) send(messages []config.WebSocketMessage, data *templating.Data) bool {
+	for _, message := range messages {
+		if waitFor(s.ctx, time.Duration(message.Delay)*time.Millisecond) != nil {
+			return false
+		}
+
+		opcode, payload, err := s.render(message, data)
+		if err != nil {
+			log.Printf("Error rendering WebSocket message: %v", err)
+			return false
+		}
+		if err := s.conn.WriteMessage(opcode, payload); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// runTimer sends the timer's message at every interval, exposing the tick number to templates as .Index
+func (s *webSocketSession) runTimer(timer config.WebSocketTimer) {
+	ticker := time.NewTicker(time.Duration(timer.Interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for tick := 0; timer.Count == 0 || tick < timer.Count; tick++ {
+		select {
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+
+		data := *s.data
+		data.Index = tick
+		if !s.send([]config.WebSocketMessage{timer.Message}, &data) {
+			return
+		}
+	}
+}
+
+// close closes the connection with the configured code once its delay has passed
+func (s *webSocketSession) close(closeConfig config.WebSocketClose) {
+	if waitFor(s.ctx, time.Duration(closeConfig.Delay)*time.Millisecond) != nil {
+		return
+	}
+
+	code := closeConfig.Code
+	if code == 0 {
+		code = websocket.CloseNormal
+	}
+	log.Printf("Closing WebSocket connection with code %d", code)
+	s.conn.Close(code, closeConfig.Reason)
+}
+
+// render renders a message, returning its opcode and payload
+func (s *webSocketSession) render(message config.WebSocketMessage, data *templating.Data) (int, []byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case message.Binary != "":
+		// Messages that weren't loaded from configuration have no decoded content
+		content := message.BinaryBytes
+		if content == nil {
+			var err error
+			if content, err = base64.StdEncoding.DecodeString(message.Binary); err != nil {
+				return 0, nil, err
+			}
+		}
+		return websocket.OpBinary, content, nil
+	case message.JSON != nil:
+		value := message.JSONTemplate
+		if value == nil {
+			value = message.JSON
+		}
+		rendered, err := s.renderer.renderValue(value, data)
+		if err != nil {
+			return 0, nil, err
+		}
+		encoded, err := json.Marshal(rendered)
+		return websocket.OpText, encoded, err
+	default:
+		text, err := s.renderer.renderStringFor(message.Text, message.TextTemplate, data)
+		return websocket.OpText, []byte(text), err
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+
+	"mock-harbor/internal/config"
+	"mock-harbor/internal/xpath"
+)
+
+// matchXPath checks the parsed XML request body against the mock's XPath matchers
+func matchXPath(matchers []config.XPathMatcher, doc *xpath.Document, report *matchReport) {
+	for i, matcher := range matchers {
+		// Expressions that failed to compile never match
+		var selected []interface{}
+		if matcher.Selector != nil {
+			for _, text := range matcher.Selector.Select(doc) {
+				selected = append(selected, xmlValue(text, matcher.Value))
+			}
+		}
+
+		if matcher.Selector == nil || !evaluateOperator(matcher.Operator(), matcher.Value, matcher.Pattern, selected) {
+			report.fail(fmt.Sprintf("xpath[%d]", i), describeOperator(matcher.Expr, matcher.Operator(), matcher.Value), describeSelected(selected))
+		}
+	}
+}
+
+// xmlValue converts XML text to the type of the expected operand so that numbers
+// and booleans in the mock compare with their textual form in the document
+func xmlValue(text string, expected interface{}) interface{} {
+	switch e := expected.(type) {
+	case float64:
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number
+		}
+	case bool:
+		if flag, err := strconv.ParseBool(text); err == nil {
+			return flag
+		}
+	case []interface{}:
+		if len(e) > 0 {
+			return xmlValue(text, e[0])
+		}
+	}
+	return text
+}
@@ -1,10 +1,13 @@
 package hotreload
 
 import (
+	"fmt"
 	"log"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"mock-harbor/internal/config"
 	"mock-harbor/internal/server"
 	"mock-harbor/internal/watcher"
 )
@@ -76,34 +79,59 @@ func (r *HotReloader) handleConfigChange(event watcher.ConfigChangeEvent) {
 			log.Printf("Error reloading service config for %s: %v", event.ServiceID, err)
 		}
 	case "mock":
-		// Mock config change - need service and usecase
+		// Mock config or body file change. The file may belong to a usecase the service
+		// only falls through to, so the service is reloaded with its active usecase,
+		// which rebuilds the whole chain. Only global config changes switch usecases.
 		// Extract usecase from path: configs/serviceA/usecases/usecase/all.json
-		usecase := extractUsecaseFromPath(event.Path)
+		usecase := extractUsecaseFromPath(r.serverManager.ConfigRoot, event.Path)
 		if usecase == "" {
 			log.Printf("Could not determine usecase from path: %s", event.Path)
 			return
 		}
-		
-		if err := r.serverManager.ReloadService(event.ServiceID, usecase); err != nil {
-			log.Printf("Error reloading mock config for %s/%s: %v", event.ServiceID, usecase, err)
+		server, exists := r.serverManager.GetServerByService(event.ServiceID)
+		if !exists || !server.LoadsUsecase(usecase) {
+			log.Printf("Ignoring change to usecase %s, which service %s doesn't load", usecase, event.ServiceID)
+			return
+		}
+
+		active, err := getServiceUsecase(r.serverManager.ConfigRoot, event.ServiceID)
+		if err != nil {
+			log.Printf("Error getting usecase for service %s: %v", event.ServiceID, err)
+			return
+		}
+		if err := r.serverManager.ReloadService(event.ServiceID, active); err != nil {
+			log.Printf("Error reloading mock config for %s/%s: %v", event.ServiceID, active, err)
 		}
 	default:
 		log.Printf("Ignoring change to unrecognized config type: %s", event.ConfigType)
 	}
 }
 
-// getServiceUsecase gets the current usecase for a service from the global config
+// getServiceUsecase gets the active usecase of a service from the global config
 func getServiceUsecase(configRoot, serviceID string) (string, error) {
-	// This is a simplified implementation that assumes the first usecase in the global config
-	// is the one we want. A more complete implementation would parse the global config
-	// and find the usecase for the specific service.
-	// For demonstration purposes, we'll use a hardcoded default if needed
-	return "default", nil
+	globalCfg, err := config.LoadGlobalConfig(filepath.Join(configRoot, "config.yaml"))
+	if err != nil {
+		return "", err
+	}
+	for _, svcRef := range globalCfg.Services {
+		if svcRef.Name == serviceID {
+			return svcRef.Usecase, nil
+		}
+	}
+	return "", fmt.Errorf("service %s is not listed in the global config", serviceID)
 }
 
-// extractUsecaseFromPath extracts the usecase name from a mock config path
-func extractUsecaseFromPath(path string) string {
+// extractUsecaseFromPath extracts the usecase name from the path of a mock config
+// or of a body file, which may be nested deeper in the usecase directory
+func extractUsecaseFromPath(configRoot, path string) string {
 	// Path format: .../configs/serviceA/usecases/usecaseName/all.json
-	dir := filepath.Dir(path)
-	return filepath.Base(dir)
+	relPath, err := filepath.Rel(configRoot, path)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(relPath, string(filepath.Separator))
+	if len(parts) < 4 || parts[1] != "usecases" {
+		return ""
+	}
+	return parts[2]
 }
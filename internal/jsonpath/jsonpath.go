@@ -0,0 +1,228 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// stepKind identifies how a path step selects values
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepWildcard
+	stepRecursive
+)
+
+// step is a single selector in a parsed path
+type step struct {
+	kind  stepKind
+	name  string
+	index int
+}
+
+// Path is a compiled JSONPath expression supporting a practical subset of the syntax:
+// $, .name, ['name'], [n], [-n], [*], .*, ..name and ..*
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// String returns the original expression
+func (p Path) String() string {
+	return p.expr
+}
+
+// Parse compiles a JSONPath expression. The leading $ is optional, so
+// items[0].qty is equivalent to $.items[0].qty.
+func Parse(expr string) (Path, error) {
+	path := Path{expr: expr}
+	rest := strings.TrimSpace(expr)
+	if rest == "" {
+		return path, fmt.Errorf("empty expression")
+	}
+
+	rest = strings.TrimPrefix(rest, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			name, remaining := readName(rest[2:])
+			if name == "" {
+				return path, fmt.Errorf("missing field name after '..' in '%s'", expr)
+			}
+			path.steps = append(path.steps, step{kind: stepRecursive, name: name})
+			rest = remaining
+		case rest[0] == '.':
+			name, remaining := readName(rest[1:])
+			if name == "" {
+				return path, fmt.Errorf("missing field name after '.' in '%s'", expr)
+			}
+			if name == "*" {
+				path.steps = append(path.steps, step{kind: stepWildcard})
+			} else {
+				path.steps = append(path.steps, step{kind: stepField, name: name})
+			}
+			rest = remaining
+		case rest[0] == '[':
+			end := closingBracket(rest)
+			if end < 0 {
+				return path, fmt.Errorf("unclosed '[' in '%s'", expr)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			parsed, err := parseBracket(selector)
+			if err != nil {
+				return path, fmt.Errorf("%v in '%s'", err, expr)
+			}
+			path.steps = append(path.steps, parsed)
+			rest = rest[end+1:]
+		default:
+			return path, fmt.Errorf("unexpected '%c' in '%s'", rest[0], expr)
+		}
+	}
+
+	return path, nil
+}
+
+// readName reads a dotted field name up to the next '.' or '['
+func readName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// closingBracket returns the position of the ']' closing the selector s starts
+// with, skipping brackets inside quoted names, or -1 if it isn't closed
+func closingBracket(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote != 0:
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseBracket parses the contents of a [...] selector
+func parseBracket(selector string) (step, error) {
+	if selector == "*" {
+		return step{kind: stepWildcard}, nil
+	}
+
+	if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+		return step{kind: stepField, name: unescape(selector[1 : len(selector)-1])}, nil
+	}
+
+	index, err := strconv.Atoi(selector)
+	if err != nil {
+		return step{}, fmt.Errorf("invalid selector '[%s]'", selector)
+	}
+	return step{kind: stepIndex, index: index}, nil
+}
+
+// unescape removes the backslashes escaping characters of a quoted name, as in ['it\'s']
+func unescape(name string) string {
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) {
+			i++
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
+// Select returns every value in the decoded JSON document matched by the path
+func (p Path) Select(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+
+	for _, s := range p.steps {
+		var next []interface{}
+		for _, value := range current {
+			next = append(next, s.apply(value)...)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+
+	return current
+}
+
+// apply selects the values matched by a single step
+func (s step) apply(value interface{}) []interface{} {
+	switch s.kind {
+	case stepField:
+		if object, ok := value.(map[string]interface{}); ok {
+			if field, exists := object[s.name]; exists {
+				return []interface{}{field}
+			}
+		}
+	case stepIndex:
+		if array, ok := value.([]interface{}); ok {
+			index := s.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index >= 0 && index < len(array) {
+				return []interface{}{array[index]}
+			}
+		}
+	case stepWildcard:
+		return children(value)
+	case stepRecursive:
+		var matches []interface{}
+		collect(value, s.name, &matches)
+		return matches
+	}
+	return nil
+}
+
+// children returns the direct members of an object or array
+func children(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			result = append(result, child)
+		}
+		return result
+	case []interface{}:
+		return append([]interface{}(nil), v...)
+	}
+	return nil
+}
+
+// collect walks the value recursively, gathering fields with the given name, or
+// every member of objects and arrays for *
+func collect(value interface{}, name string, matches *[]interface{}) {
+	if name == "*" {
+		*matches = append(*matches, children(value)...)
+	} else if object, ok := value.(map[string]interface{}); ok {
+		if field, exists := object[name]; exists {
+			*matches = append(*matches, field)
+		}
+	}
+	for _, child := range children(value) {
+		collect(child, name, matches)
+	}
+}
@@ -0,0 +1,134 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr  string
+		steps []step
+	}{
+		{"$", nil},
+		{"$.items", []step{{kind: stepField, name: "items"}}},
+		{"items[0].qty", []step{{kind: stepField, name: "items"}, {kind: stepIndex, index: 0}, {kind: stepField, name: "qty"}}},
+		{"$.items[-1]", []step{{kind: stepField, name: "items"}, {kind: stepIndex, index: -1}}},
+		{"$['a.b']", []step{{kind: stepField, name: "a.b"}}},
+		{`$["a"]`, []step{{kind: stepField, name: "a"}}},
+		{"$['a]b']", []step{{kind: stepField, name: "a]b"}}},
+		{`$['it\'s']`, []step{{kind: stepField, name: "it's"}}},
+		{"$[ 'a' ][ 2 ]", []step{{kind: stepField, name: "a"}, {kind: stepIndex, index: 2}}},
+		{"$.*", []step{{kind: stepWildcard}}},
+		{"$[*]", []step{{kind: stepWildcard}}},
+		{"$..id", []step{{kind: stepRecursive, name: "id"}}},
+		{"$..*", []step{{kind: stepRecursive, name: "*"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			path, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(path.steps, tt.steps) {
+				t.Errorf("Parse(%q) steps = %+v, want %+v", tt.expr, path.steps, tt.steps)
+			}
+			if path.String() != tt.expr {
+				t.Errorf("String() = %q, want %q", path.String(), tt.expr)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"  ",
+		"$.",
+		"$..",
+		"$[0",
+		"$['a]",
+		`$['a\']`,
+		"$[a]",
+		"$['a'x]",
+		"$[1.5]",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+const testDocument = `{
+	"id": 1,
+	"a]b": "bracket",
+	"items": [
+		{"id": 10, "qty": 2, "tags": ["x", "y"]},
+		{"id": 11, "qty": 5}
+	],
+	"owner": {"id": 2, "name": "Ana"}
+}`
+
+func TestSelect(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(testDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []string // Selected values as JSON, in any order
+	}{
+		{"$", []string{mustMarshal(t, doc)}},
+		{"$.id", []string{"1"}},
+		{"owner.name", []string{`"Ana"`}},
+		{"$.items[1].qty", []string{"5"}},
+		{"$.items[-1].id", []string{"11"}},
+		{"$.items[-3]", nil},
+		{"$.items[2]", nil},
+		{"$['a]b']", []string{`"bracket"`}},
+		{"$.items[*].id", []string{"10", "11"}},
+		{"$.owner.*", []string{"2", `"Ana"`}},
+		{"$..id", []string{"1", "10", "11", "2"}},
+		{"$..tags[0]", []string{`"x"`}},
+		{"$.items..*", []string{
+			`{"id":10,"qty":2,"tags":["x","y"]}`, `{"id":11,"qty":5}`,
+			"10", "2", `["x","y"]`, `"x"`, `"y"`, "11", "5",
+		}},
+		{"$.missing", nil},
+		{"$.id.name", nil},
+		{"$.owner[0]", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			path, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+
+			var got []string
+			for _, value := range path.Select(doc) {
+				got = append(got, mustMarshal(t, value))
+			}
+			sort.Strings(got)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Select(%q) = %v, want %v", tt.expr, got, want)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, value interface{}) string {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(encoded)
+}
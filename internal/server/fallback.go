@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"mock-harbor/internal/config"
+	"mock-harbor/internal/handler"
+	"mock-harbor/internal/validation"
+)
+
+// LoadFallbacks configures how the server answers requests that match no mock of the
+// usecase, loading the chain of usecases that unmatched requests fall through to
+func (s *MockServer) LoadFallbacks(configRoot, usecase string, serviceConfig *config.ServiceConfig) error {
+	s.Usecases = []string{usecase}
+	s.Handler.Usecase = usecase
+	visited := map[string]bool{usecase: true}
+	return s.loadFallback(s.Handler, configRoot, usecase, serviceConfig, visited)
+}
+
+// loadFallback resolves the fallback of one usecase and recurses into the usecase it falls through to
+func (s *MockServer) loadFallback(h *handler.MockHandler, configRoot, usecase string, serviceConfig *config.ServiceConfig, visited map[string]bool) error {
+	serviceName := s.ServiceName
+	fallback, err := config.LoadFallbackConfig(configRoot, serviceName, usecase, serviceConfig)
+	if err != nil {
+		return err
+	}
+	if fallback == nil {
+		return nil
+	}
+
+	fallbackPath := filepath.Join(configRoot, serviceName, "usecases", usecase, "fallback.json")
+	if !config.HasUsecaseFallback(configRoot, serviceName, usecase) {
+		fallbackPath = filepath.Join(configRoot, serviceName, "config.yaml")
+	}
+	validationResult := validation.ValidateFallbackConfig(fallback, fallbackPath)
+	if !validationResult.IsValid() {
+		return fmt.Errorf("fallback configuration validation failed: %s", validationResult.ErrorMessages())
+	}
+
+	h.Fallback = fallback
+
+	// Each usecase is tried at most once, which also stops a service-wide
+	// fallback usecase from falling through to itself
+	next := fallback.Usecase
+	if next == "" || visited[next] {
+		return nil
+	}
+	visited[next] = true
+
+	mocks, err := config.LoadMockConfigs(configRoot, serviceName, next)
+	if err != nil {
+		return fmt.Errorf("error loading fallback usecase '%s': %w", next, err)
+	}
+
+	mockConfigPath := filepath.Join(configRoot, serviceName, "usecases", next, "all.json")
+	validationResult = validation.ValidateMockConfigs(mocks, mockConfigPath, config.HasUsecaseFallback(configRoot, serviceName, next))
+	if !validationResult.IsValid() {
+		return fmt.Errorf("fallback usecase '%s' validation failed: %s", next, validationResult.ErrorMessages())
+	}
+	for _, warning := range validationResult.Warnings {
+		log.Printf("Warning: %s", warning.Error())
+	}
+
+	log.Printf("Unmatched requests for %s/%s fall through to usecase %s", serviceName, usecase, next)
+	h.Next = handler.NewMockHandler(mocks, nil)
+	h.Next.Usecase = next
+	if serviceConfig != nil && serviceConfig.RandomSeed != nil {
+		h.Next.SetSeed(*serviceConfig.RandomSeed)
+	}
+	s.Usecases = append(s.Usecases, next)
+	return s.loadFallback(h.Next, configRoot, next, serviceConfig, visited)
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"mock-harbor/internal/config"
+)
+
+// ResetPath is the path that starts the response sequences of the requested service over
+const ResetPath = "/__mock-harbor/reset"
+
+// Listener serves one port, routing each request to the service configured for its host.
+// Services without hosts answer requests for any host not claimed by another service.
+type Listener struct {
+	Port   int
+	Server *http.Server
+
+	mutex    sync.RWMutex
+	services map[string]*MockServer // Maps service names to servers
+	started  bool
+
+	// ctx is the base context of requests, canceled by Stop to end streams and WebSocket connections
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewListener creates a listener for the given port
+func NewListener(port int) *Listener {
+	l := &Listener{
+		Port:     port,
+		services: make(map[string]*MockServer),
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	l.Server = &http.Server{
+		Addr:        fmt.Sprintf(":%d", port),
+		Handler:     l,
+		BaseContext: func(net.Listener) context.Context { return l.ctx },
+	}
+	return l
+}
+
+// Register adds a service to the listener, replacing any server for the same service,
+// and returns warnings about hosts that another service on the port already claims
+func (l *Listener) Register(server *MockServer) []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var warnings []string
+	for _, name := range l.serviceNames() {
+		if name == server.ServiceName {
+			continue
+		}
+		other := l.services[name]
+		if len(server.Hosts) == 0 && len(other.Hosts) == 0 {
+			warnings = append(warnings, fmt.Sprintf("Port %d is already in use by service %s, both serve any host", l.Port, name))
+		}
+		for _, host := range sharedHosts(server.Hosts, other.Hosts) {
+			warnings = append(warnings, fmt.Sprintf("Host %s on port %d is already served by service %s", host, l.Port, name))
+		}
+	}
+
+	l.services[server.ServiceName] = server
+	return warnings
+}
+
+// Unregister removes a service from the listener and reports whether any services remain
+func (l *Listener) Unregister(serviceName string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.services, serviceName)
+	return len(l.services) > 0
+}
+
+// Start begins listening for requests in the background, unless already started
+func (l *Listener) Start() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.started {
+		return
+	}
+	l.started = true
+
+	log.Printf("Listening on port %d for %s", l.Port, strings.Join(l.serviceNames(), ", "))
+	go func() {
+		if err := l.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error listening on port %d: %v", l.Port, err)
+		}
+	}()
+}
+
+// Stop gracefully shuts down the listener. Streamed responses and WebSocket connections
+// are ended right away, as they would otherwise keep it running until the context is done.
+func (l *Listener) Stop(ctx context.Context) error {
+	log.Printf("Stopping listener on port %d", l.Port)
+	l.cancel()
+	return l.Server.Shutdown(ctx)
+}
+
+// ServeHTTP routes the request to the service for its host
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := requestHost(r)
+
+	server, found := l.route(host)
+	if !found {
+		log.Printf("No service configured for host %s on port %d", host, l.Port)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No service configured for host " + host))
+		return
+	}
+
+	if r.Method == http.MethodPost && r.URL.Path == ResetPath {
+		log.Printf("Resetting response sequences for service %s", server.ServiceName)
+		server.Handler.ResetSequences()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	server.Handler.ServeHTTP(w, r)
+}
+
+// route finds the service for a host, preferring one that lists the host
+// over one that serves any host. Ties are broken by service name.
+func (l *Listener) route(host string) (*MockServer, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	var fallback *MockServer
+	for _, name := range l.serviceNames() {
+		server := l.services[name]
+		if len(server.Hosts) == 0 {
+			if fallback == nil {
+				fallback = server
+			}
+			continue
+		}
+		for _, serverHost := range server.Hosts {
+			if config.NormalizeHost(serverHost) == host {
+				return server, true
+			}
+		}
+	}
+	return fallback, fallback != nil
+}
+
+// serviceNames returns the names of the registered services in a stable order
+func (l *Listener) serviceNames() []string {
+	names := make([]string, 0, len(l.services))
+	for name := range l.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// requestHost returns the host a request was sent to, taken from the Host header without its port
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return config.NormalizeHost(host)
+}
+
+// sharedHosts returns the host names listed by both services
+func sharedHosts(a, b []string) []string {
+	var shared []string
+	for _, host := range a {
+		for _, other := range b {
+			if config.NormalizeHost(host) == config.NormalizeHost(other) {
+				shared = append(shared, config.NormalizeHost(host))
+				break
+			}
+		}
+	}
+	return shared
+}
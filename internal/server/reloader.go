@@ -1,27 +1,14 @@
 package server
 
 import (
-	"context"
 	"fmt"
 	"log"
-	"net/http"
 	"path/filepath"
-	"time"
 
 	"mock-harbor/internal/config"
 	"mock-harbor/internal/validation"
 )
 
-// isPortInUse checks if a port is already in use by another service
-func (m *ServerManager) isPortInUse(port int, serviceName string) (string, bool) {
-	for name, server := range m.serviceMap {
-		if server.Port == port && name != serviceName {
-			return name, true
-		}
-	}
-	return "", false
-}
-
 // GetServerByService returns a server by its service name
 func (m *ServerManager) GetServerByService(serviceName string) (*MockServer, bool) {
 	m.mutex.Lock()
@@ -56,42 +43,23 @@ func (m *ServerManager) ReloadService(serviceName, usecase string) error {
 	
 	// Validate mock configurations
 	mockConfigPath := filepath.Join(m.ConfigRoot, serviceName, "usecases", usecase, "all.json")
-	validationResult = validation.ValidateMockConfigs(mocks, mockConfigPath)
+	validationResult = validation.ValidateMockConfigs(mocks, mockConfigPath, config.HasUsecaseFallback(m.ConfigRoot, serviceName, usecase))
 	if !validationResult.IsValid() {
 		return fmt.Errorf("mock configuration validation failed: %s", validationResult.ErrorMessages())
 	}
-	
-	// Check if service exists
-	existingServer, exists := m.GetServerByService(serviceName)
-	
-	// If server exists, stop it
-	if exists {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		
-		log.Printf("Stopping server for service %s before reloading", serviceName)
-		if err := existingServer.Stop(ctx); err != nil {
-			log.Printf("Warning: Error stopping server during reload: %v", err)
-		}
-		
-		// Give the server a moment to fully stop
-		time.Sleep(100 * time.Millisecond)
+	for _, warning := range validationResult.Warnings {
+		log.Printf("Warning: %s", warning.Error())
 	}
 	
 	// Create new server with updated config
 	mockServer := NewMockServer(serviceName, svcCfg.Port, mocks, svcCfg)
+	if err := mockServer.LoadFallbacks(m.ConfigRoot, usecase, svcCfg); err != nil {
+		return fmt.Errorf("error loading fallback: %w", err)
+	}
 	
-	// Add the server (this will replace the existing one if present)
+	// Add the server, replacing the existing one in place when it keeps its port
 	m.AddServer(mockServer)
 	
-	// Start the new server
-	go func() {
-		log.Printf("Starting reloaded server for %s on port %d", serviceName, svcCfg.Port)
-		if err := mockServer.Start(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Error starting reloaded server %s: %v", serviceName, err)
-		}
-	}()
-	
 	log.Printf("Successfully reloaded configuration for service %s", serviceName)
 	return nil
 }
@@ -138,30 +106,7 @@ func (m *ServerManager) ReloadGlobalConfig() error {
 		if !processedServices[name] {
 			log.Printf("Service %s was removed from global config, stopping server", name)
 			
-			// Get the server
-			server, exists := m.GetServerByService(name)
-			if exists {
-				// Stop the server
-				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				if err := server.Stop(ctx); err != nil {
-					log.Printf("Error stopping removed server %s: %v", name, err)
-				}
-				cancel()
-				
-				// Remove from manager
-				m.mutex.Lock()
-				delete(m.serviceMap, name)
-				delete(m.portMap, server.Port)
-				
-				// Remove from servers slice
-				for i, s := range m.Servers {
-					if s.ServiceName == name {
-						m.Servers = append(m.Servers[:i], m.Servers[i+1:]...)
-						break
-					}
-				}
-				m.mutex.Unlock()
-			}
+			m.RemoveServer(name)
 		}
 	}
 	
@@ -2,9 +2,7 @@ package server
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 
@@ -16,8 +14,11 @@ import (
 type MockServer struct {
 	ServiceName string
 	Port        int
-	Server      *http.Server
-	Handler     *handler.MockHandler
+	// Hosts the service answers for when sharing its port, any host if empty
+	Hosts   []string
+	Handler *handler.MockHandler
+	// Usecases lists the usecase serving requests followed by those it falls through to
+	Usecases []string
 }
 
 // NewMockServer creates a new mock server for the given service
@@ -29,38 +30,41 @@ func NewMockServer(serviceName string, port int, mocks []config.MockConfig, serv
 	}
 	
 	mockHandler := handler.NewMockHandler(mocks, delayConfig)
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mockHandler,
-	}
-	
-	return &MockServer{
+	server := &MockServer{
 		ServiceName: serviceName,
 		Port:        port,
-		Server:      server,
 		Handler:     mockHandler,
 	}
+	if serviceConfig != nil {
+		mockHandler.Diagnostics = serviceConfig.Diagnostics
+		mockHandler.Compression = serviceConfig.Compression
+		server.Hosts = serviceConfig.Hosts
+		if serviceConfig.RandomSeed != nil {
+			mockHandler.SetSeed(*serviceConfig.RandomSeed)
+		}
+	}
+	
+	return server
 }
 
-// Start begins listening for requests
-func (s *MockServer) Start() error {
-	log.Printf("Starting mock server for %s on port %d", s.ServiceName, s.Port)
-	return s.Server.ListenAndServe()
-}
-
-// Stop gracefully shuts down the server
-func (s *MockServer) Stop(ctx context.Context) error {
-	log.Printf("Stopping mock server for %s", s.ServiceName)
-	return s.Server.Shutdown(ctx)
+// LoadsUsecase reports whether the server serves the usecase or falls through to it
+func (s *MockServer) LoadsUsecase(usecase string) bool {
+	for _, loaded := range s.Usecases {
+		if loaded == usecase {
+			return true
+		}
+	}
+	return false
 }
 
 // ServerManager manages multiple mock servers
 type ServerManager struct {
-	Servers     []*MockServer
-	ConfigRoot  string
-	serviceMap  map[string]*MockServer // Maps service names to servers
-	portMap     map[int]bool           // Tracks used ports
-	mutex       sync.Mutex              // Protects concurrent access during reloading
+	Servers    []*MockServer
+	ConfigRoot string
+	serviceMap map[string]*MockServer // Maps service names to servers
+	listeners  map[int]*Listener      // Maps ports to the listeners shared by their services
+	running    bool                   // Whether listeners are started as servers are added
+	mutex      sync.Mutex             // Protects concurrent access during reloading
 }
 
 // NewServerManager creates a new server manager
@@ -69,11 +73,12 @@ func NewServerManager(configRoot string) *ServerManager {
 		Servers:    make([]*MockServer, 0),
 		ConfigRoot: configRoot,
 		serviceMap: make(map[string]*MockServer),
-		portMap:    make(map[int]bool),
+		listeners:  make(map[int]*Listener),
 	}
 }
 
-// AddServer adds a new server to the manager
+// AddServer adds a new server to the manager, sharing the listener of any
+// other service on the same port
 func (m *ServerManager) AddServer(server *MockServer) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -81,39 +86,81 @@ func (m *ServerManager) AddServer(server *MockServer) {
 	// Check if we already have a server for this service
 	if existing, exists := m.serviceMap[server.ServiceName]; exists {
 		log.Printf("Replacing existing server for service %s", server.ServiceName)
-		// Remove the existing server from the slice
-		for i, s := range m.Servers {
-			if s.ServiceName == server.ServiceName {
-				m.Servers = append(m.Servers[:i], m.Servers[i+1:]...)
-				break
-			}
+		if existing.Port != server.Port {
+			// Leave the old port, stopping its listener if no other service uses it
+			m.removeServer(existing)
+		} else {
+			// Keep the listener, registering the new server replaces the existing one
+			m.removeFromServers(existing.ServiceName)
 		}
-		// Mark the port as free
-		delete(m.portMap, existing.Port)
 	}
 	
-	// Check if the port is already in use by a different service
-	if service, inUse := m.isPortInUse(server.Port, server.ServiceName); inUse {
-		log.Printf("Warning: Port %d is already in use by service %s", server.Port, service)
+	// Share the listener for the port, warning about hosts claimed by other services
+	listener, exists := m.listeners[server.Port]
+	if !exists {
+		listener = NewListener(server.Port)
+		m.listeners[server.Port] = listener
+	}
+	for _, warning := range listener.Register(server) {
+		log.Printf("Warning: %s", warning)
 	}
 	
 	// Add the new server
 	m.Servers = append(m.Servers, server)
 	m.serviceMap[server.ServiceName] = server
-	m.portMap[server.Port] = true
+	
+	if m.running {
+		listener.Start()
+	}
+}
+
+// RemoveServer removes the server for a service, stopping its listener
+// if no other service uses the port
+func (m *ServerManager) RemoveServer(serviceName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	if server, exists := m.serviceMap[serviceName]; exists {
+		m.removeServer(server)
+	}
+}
+
+// removeServer removes a server from the manager and its listener. The caller must hold the mutex.
+func (m *ServerManager) removeServer(server *MockServer) {
+	m.removeFromServers(server.ServiceName)
+	
+	listener, exists := m.listeners[server.Port]
+	if !exists || listener.Unregister(server.ServiceName) {
+		return
+	}
+	delete(m.listeners, server.Port)
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := listener.Stop(ctx); err != nil {
+		log.Printf("Error stopping listener on port %d: %v", server.Port, err)
+	}
+}
+
+// removeFromServers removes a service from the list of servers. The caller must hold the mutex.
+func (m *ServerManager) removeFromServers(serviceName string) {
+	delete(m.serviceMap, serviceName)
+	for i, s := range m.Servers {
+		if s.ServiceName == serviceName {
+			m.Servers = append(m.Servers[:i], m.Servers[i+1:]...)
+			break
+		}
+	}
 }
 
-// StartAll starts all managed servers
+// StartAll starts listening on the ports of all managed servers
 func (m *ServerManager) StartAll() {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	
-	for _, server := range m.Servers {
-		go func(s *MockServer) {
-			if err := s.Start(); err != nil && err != http.ErrServerClosed {
-				log.Printf("Error starting server %s: %v", s.ServiceName, err)
-			}
-		}(server)
+	m.running = true
+	for _, listener := range m.listeners {
+		listener.Start()
 	}
 }
 
@@ -125,9 +172,10 @@ func (m *ServerManager) StopAll() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
-	for _, server := range m.Servers {
-		if err := server.Stop(ctx); err != nil {
-			log.Printf("Error stopping server %s: %v", server.ServiceName, err)
+	m.running = false
+	for _, listener := range m.listeners {
+		if err := listener.Stop(ctx); err != nil {
+			log.Printf("Error stopping listener on port %d: %v", listener.Port, err)
 		}
 	}
 }
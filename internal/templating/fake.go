@@ -0,0 +1,191 @@
+package templating
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Word lists the fake data generator draws from
+var (
+	firstNames = []string{"Alice", "Bruno", "Chloe", "Daniel", "Elena", "Farid", "Grace", "Hiro", "Ines", "Jonas",
+		"Kavya", "Liam", "Maya", "Noah", "Olga", "Pedro", "Quinn", "Rosa", "Samir", "Tara", "Umar", "Vera", "Wei", "Yara"}
+	lastNames = []string{"Anderson", "Becker", "Costa", "Dubois", "Eriksson", "Fischer", "Garcia", "Hansen", "Ito", "Jensen",
+		"Kowalski", "Lopez", "Müller", "Nakamura", "Okafor", "Petrov", "Rossi", "Silva", "Tanaka", "Novak", "Walker", "Young"}
+	streetNames = []string{"Maple", "Oak", "Harbor", "Station", "Mill", "Church", "Park", "River", "Lake", "Hill", "Elm", "King"}
+	streetTypes = []string{"Street", "Avenue", "Road", "Lane", "Way", "Drive", "Court"}
+	cities      = []string{"Springfield", "Riverside", "Fairview", "Greenville", "Bristol", "Clinton", "Georgetown", "Madison",
+		"Salem", "Franklin", "Ashland", "Milton"}
+	companyWords = []string{"Acme", "Globex", "Initech", "Umbrella", "Stark", "Wayne", "Wonka", "Cyberdyne", "Soylent", "Hooli"}
+	companyTypes = []string{"Inc", "LLC", "Ltd", "Group", "Labs", "Systems"}
+	emailDomains = []string{"example.com", "example.org", "example.net", "mail.test"}
+	loremWords   = strings.Fields("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor " +
+		"incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco " +
+		"laboris nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit esse " +
+		"cillum fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui officia " +
+		"deserunt mollit anim id est laborum")
+)
+
+// Faker generates fake data for response templates. Fakers created with the same
+// seed key generate the same values in the same order.
+type Faker struct {
+	rng *rand.Rand
+}
+
+// NewFaker creates a fake data generator seeded from the key, or randomly if the key is empty
+func NewFaker(key string) *Faker {
+	seed := time.Now().UnixNano()
+	if key != "" {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		seed = int64(h.Sum64())
+	}
+	return &Faker{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Funcs returns the fake data functions bound to this generator
+func (f *Faker) Funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"fakeFirstName": f.FirstName,
+		"fakeLastName":  f.LastName,
+		"fakeName":      f.Name,
+		"fakeEmail":     f.Email,
+		"fakePhone":     f.Phone,
+		"fakeStreet":    f.Street,
+		"fakeCity":      f.City,
+		"fakeZip":       f.Zip,
+		"fakeAddress":   f.Address,
+		"fakeCompany":   f.Company,
+		"lorem":         f.Lorem,
+		"loremSentence": f.Sentence,
+		"randInt":       f.Int,
+		"randFloat":     f.Float,
+		"randBool":      f.Bool,
+		"randDate":      f.Date,
+		"pick":          f.Pick,
+		"fakeUUID":      f.UUID,
+	}
+}
+
+// FirstName returns a random first name
+func (f *Faker) FirstName() string {
+	return f.choose(firstNames)
+}
+
+// LastName returns a random last name
+func (f *Faker) LastName() string {
+	return f.choose(lastNames)
+}
+
+// Name returns a random full name
+func (f *Faker) Name() string {
+	return f.FirstName() + " " + f.LastName()
+}
+
+// Email returns a random email address at a reserved example domain
+func (f *Faker) Email() string {
+	local := strings.ToLower(f.FirstName() + "." + f.LastName())
+	return fmt.Sprintf("%s%d@%s", local, f.rng.Intn(100), f.choose(emailDomains))
+}
+
+// Phone returns a random phone number in the fictional 555 range
+func (f *Faker) Phone() string {
+	return fmt.Sprintf("+1-%03d-555-%04d", 200+f.rng.Intn(800), f.rng.Intn(10000))
+}
+
+// Street returns a random street address
+func (f *Faker) Street() string {
+	return fmt.Sprintf("%d %s %s", 1+f.rng.Intn(9999), f.choose(streetNames), f.choose(streetTypes))
+}
+
+// City returns a random city name
+func (f *Faker) City() string {
+	return f.choose(cities)
+}
+
+// Zip returns a random five digit postal code
+func (f *Faker) Zip() string {
+	return fmt.Sprintf("%05d", f.rng.Intn(100000))
+}
+
+// Address returns a random single line postal address
+func (f *Faker) Address() string {
+	return fmt.Sprintf("%s, %s %s", f.Street(), f.City(), f.Zip())
+}
+
+// Company returns a random company name
+func (f *Faker) Company() string {
+	return f.choose(companyWords) + " " + f.choose(companyTypes)
+}
+
+// Lorem returns the given number of lorem ipsum words
+func (f *Faker) Lorem(words int) string {
+	result := make([]string, 0, words)
+	for i := 0; i < words; i++ {
+		result = append(result, f.choose(loremWords))
+	}
+	return strings.Join(result, " ")
+}
+
+// Sentence returns a capitalized lorem ipsum sentence of four to twelve words
+func (f *Faker) Sentence() string {
+	text := f.Lorem(4 + f.rng.Intn(9))
+	return strings.ToUpper(text[:1]) + text[1:] + "."
+}
+
+// Int returns a random integer between min and max, inclusive
+func (f *Faker) Int(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + f.rng.Intn(max-min+1)
+}
+
+// Float returns a random number between min and max, rounded to two decimals
+func (f *Faker) Float(min, max float64) float64 {
+	value := min + f.rng.Float64()*(max-min)
+	return float64(int64(value*100+0.5)) / 100
+}
+
+// Bool returns a random boolean
+func (f *Faker) Bool() bool {
+	return f.rng.Intn(2) == 1
+}
+
+// Date returns a random time between two dates given as YYYY-MM-DD
+func (f *Faker) Date(from, to string) (time.Time, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, err
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !end.After(start) {
+		return start, nil
+	}
+	return start.Add(time.Duration(f.rng.Int63n(int64(end.Sub(start))))), nil
+}
+
+// Pick returns one of the given values at random
+func (f *Faker) Pick(values ...interface{}) interface{} {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[f.rng.Intn(len(values))]
+}
+
+// UUID returns a random version 4 UUID, reproduced by fakers with the same seed key
+func (f *Faker) UUID() string {
+	var b [16]byte
+	f.rng.Read(b[:])
+	return formatUUID(b)
+}
+
+// choose returns a random element of a word list
+func (f *Faker) choose(words []string) string {
+	return words[f.rng.Intn(len(words))]
+}
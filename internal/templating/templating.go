@@ -0,0 +1,239 @@
+package templating
+
+import (
+	"crypto/rand"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Data describes the matched request to response templates
+type Data struct {
+	Method string
+	Path   string
+	// Params holds the path parameters captured by the mock path
+	Params map[string]string
+	// Query holds the first value of each query parameter
+	Query map[string]string
+	// Headers holds the first value of each header, keyed by canonical name
+	Headers map[string]string
+	// Cookies holds the value of each cookie
+	Cookies map[string]string
+	// Body holds the decoded JSON request body, nil if it isn't JSON
+	Body interface{}
+	// Index is the position of the item being rendered by a $repeat directive,
+	// the repetition of a stream or the tick of a WebSocket timer
+	Index int
+	// Message holds the WebSocket message being replied to, decoded if it is JSON
+	Message interface{}
+}
+
+// IsTemplate reports whether the text contains template actions
+func IsTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// IsSingleAction reports whether the template consists of a single action and no
+// text around it, such as {{.Body.id}}, whose output keeps its type in JSON values
+func IsSingleAction(tmpl *template.Template) bool {
+	if tmpl.Tree == nil || tmpl.Tree.Root == nil || len(tmpl.Tree.Root.Nodes) != 1 {
+		return false
+	}
+	_, ok := tmpl.Tree.Root.Nodes[0].(*parse.ActionNode)
+	return ok
+}
+
+// Parse parses text as a response template with the built-in functions, rejecting
+// references to fields the template data doesn't have, such as .params for .Params
+func Parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs(Funcs()).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFields(tmpl.Tree.Root, true); err != nil {
+		return nil, fmt.Errorf("template: %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// checkFields checks the fields referenced from the template data below a node.
+// Fields of dot are only checked where dot is the template data, which range
+// and with change within their bodies; fields of $ are checked everywhere.
+func checkFields(node parse.Node, dotIsData bool) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkFields(child, dotIsData); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkFields(n.Pipe, dotIsData)
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode, dotIsData, dotIsData)
+	case *parse.RangeNode:
+		return checkBranch(&n.BranchNode, dotIsData, false)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode, dotIsData, false)
+	case *parse.TemplateNode:
+		return checkFields(n.Pipe, dotIsData)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkFields(cmd, dotIsData); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkFields(arg, dotIsData); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return checkFields(n.Node, dotIsData)
+	case *parse.FieldNode:
+		if dotIsData {
+			return checkDataField(n.Ident[0])
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			return checkDataField(n.Ident[1])
+		}
+	}
+	return nil
+}
+
+// checkBranch checks an if, range or with action, whose body may see a different dot
+func checkBranch(n *parse.BranchNode, dotIsData, bodyDotIsData bool) error {
+	if err := checkFields(n.Pipe, dotIsData); err != nil {
+		return err
+	}
+	if err := checkFields(n.List, bodyDotIsData); err != nil {
+		return err
+	}
+	return checkFields(n.ElseList, dotIsData)
+}
+
+// checkDataField reports an error if the template data has no field of the name
+func checkDataField(name string) error {
+	dataType := reflect.TypeOf(Data{})
+	if _, exists := dataType.FieldByName(name); exists {
+		return nil
+	}
+	for i := 0; i < dataType.NumField(); i++ {
+		if field := dataType.Field(i).Name; strings.EqualFold(field, name) {
+			return fmt.Errorf("unknown field .%s, did you mean .%s?", name, field)
+		}
+	}
+	return fmt.Errorf("unknown field .%s", name)
+}
+
+// Funcs returns the functions available to response templates when they are parsed.
+// The fake data functions are placeholders until an Executor binds them to a generator.
+func Funcs() template.FuncMap {
+	funcs := builtinFuncs()
+	var unbound *Faker
+	for name, fn := range unbound.Funcs() {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// builtinFuncs returns the template functions that don't draw fake data
+func builtinFuncs() template.FuncMap {
+	return template.FuncMap{
+		"now":    time.Now,
+		"uuid":   NewUUID,
+		"repeat": repeat,
+	}
+}
+
+// Executor renders the templates of one response, drawing fake data from one generator.
+// Each template is bound to the generator once, however often it is rendered.
+// An Executor is not safe for concurrent use.
+type Executor struct {
+	set   *template.Template
+	bound map[*template.Template]*template.Template
+}
+
+// NewExecutor creates an executor drawing fake data from faker
+func NewExecutor(faker *Faker) *Executor {
+	set := template.New("").Option("missingkey=zero").Funcs(builtinFuncs()).Funcs(faker.Funcs())
+	return &Executor{set: set, bound: make(map[*template.Template]*template.Template)}
+}
+
+// Execute renders a template returned by Parse for the request described by data
+func (e *Executor) Execute(tmpl *template.Template, data *Data) (string, error) {
+	bound, exists := e.bound[tmpl]
+	if !exists {
+		// Parse trees aren't modified by execution, so they are shared rather than copied
+		for _, associated := range tmpl.Templates() {
+			if associated != tmpl {
+				if _, err := e.set.New(associated.Name()).AddParseTree(associated.Name(), associated.Tree); err != nil {
+					return "", err
+				}
+			}
+		}
+		var err error
+		if bound, err = e.set.New(tmpl.Name()).AddParseTree(tmpl.Name(), tmpl.Tree); err != nil {
+			return "", err
+		}
+		e.bound[tmpl] = bound
+	}
+
+	var sb strings.Builder
+	if err := bound.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// MaxRepeat is the largest count repeat and $repeat directives accept, so that a
+// count taken from the request can't exhaust memory. It is set by -max-repeat.
+var MaxRepeat = 1000
+
+// CheckRepeat returns an error if n is above MaxRepeat
+func CheckRepeat(n int) error {
+	if n > MaxRepeat {
+		return fmt.Errorf("repeat count %d is above the maximum of %d", n, MaxRepeat)
+	}
+	return nil
+}
+
+// repeat returns the numbers from 0 to n-1, for ranging over in a template
+func repeat(n int) ([]int, error) {
+	if err := CheckRepeat(n); err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		n = 0
+	}
+	numbers := make([]int, n)
+	for i := range numbers {
+		numbers[i] = i
+	}
+	return numbers, nil
+}
+
+// NewUUID returns a random version 4 UUID, which unlike fakeUUID is never reproduced by a seed
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return formatUUID(b)
+}
+
+// formatUUID formats random bytes as a version 4 UUID
+func formatUUID(b [16]byte) string {
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,217 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"mock-harbor/internal/config"
+)
+
+// checkUnreachableMocks warns about mocks that can never be selected because another
+// mock ranked ahead of them matches every request they match
+func checkUnreachableMocks(mocks []config.MockConfig, fileName string) []ValidationError {
+	var warnings []ValidationError
+
+	// Rank and key every mock once. Mocks whose criteria can't be encoded get an
+	// empty key, which no other mock shares.
+	specs := make([]config.Specificity, len(mocks))
+	keys := make([]string, len(mocks))
+	for i, mock := range mocks {
+		specs[i] = mock.Specificity()
+		keys[i], _ = criteriaKey(mock.Request)
+	}
+
+	// A mock can only be shadowed by mocks the route index returns for its method and
+	// path. Templates are looked up by their source, which parameter and catch-all
+	// segments of other templates match.
+	routes := config.NewRouteTable(mocks)
+	for i, mock := range mocks {
+		for _, j := range routes.Lookup(mock.Request.Method, mock.Request.Path) {
+			if i == j {
+				continue
+			}
+			other := mocks[j]
+
+			// Only mocks ranked ahead can shadow this one; file order breaks ties
+			order := specs[j].Compare(specs[i])
+			if order < 0 || (order == 0 && j > i) {
+				continue
+			}
+
+			// Identical criteria are already reported as duplicate endpoints
+			if keys[i] != "" && keys[i] == keys[j] {
+				continue
+			}
+
+			if coversRequest(other.Request, mock.Request) {
+				warnings = append(warnings, ValidationError{
+					File:    fileName,
+					Field:   fmt.Sprintf("[%d].request", i),
+					Message: fmt.Sprintf("mock is unreachable, every request it matches is matched by [%d] which ranks ahead of it", j),
+				})
+				break
+			}
+		}
+	}
+
+	return warnings
+}
+
+// coversRequest reports whether every request matched by b is also matched by a.
+// It is conservative: criteria it cannot compare are assumed not to cover.
+func coversRequest(a, b config.RequestConfig) bool {
+	// An empty method or path in a matches any
+	if (a.Method != "" && !strings.EqualFold(a.Method, b.Method)) || !coversPath(a, b) {
+		return false
+	}
+
+	// Every criterion of a must also be a criterion of b
+	if !subsetOf(a.Query, b.Query) || !subsetOf(canonicalHeaders(a.Headers), canonicalHeaders(b.Headers)) ||
+		!subsetOf(a.Cookies, b.Cookies) || !subsetOf(a.Form, b.Form) {
+		return false
+	}
+	if !elementsOf(a.BodyMatchers, b.BodyMatchers) || !elementsOf(a.Multipart, b.Multipart) {
+		return false
+	}
+	if len(a.XPath) > 0 && (!reflect.DeepEqual(a.Namespaces, b.Namespaces) || !elementsOf(a.XPath, b.XPath)) {
+		return false
+	}
+	if a.Body != nil && (!jsonEqual(a.Body, b.Body) || a.ArrayMatch != b.ArrayMatch) {
+		return false
+	}
+	if a.BodyEquals != nil && (b.BodyEquals == nil || *a.BodyEquals != *b.BodyEquals) {
+		return false
+	}
+	if a.BodyContains != "" && a.BodyContains != b.BodyContains {
+		return false
+	}
+	if a.BodyRegex != "" && a.BodyRegex != b.BodyRegex {
+		return false
+	}
+
+	// Nested criteria are only compared for being identical
+	if !elementsOf(a.AllOf, b.AllOf) {
+		return false
+	}
+	if len(a.AnyOf) > 0 && !jsonEqual(a.AnyOf, b.AnyOf) {
+		return false
+	}
+	if a.Not != nil && (b.Not == nil || !jsonEqual(a.Not, b.Not)) {
+		return false
+	}
+
+	return true
+}
+
+// coversPath reports whether every path matched by b is also matched by a
+func coversPath(a, b config.RequestConfig) bool {
+	if a.Path == "" && a.PathRegex == "" {
+		return true
+	}
+	if b.Path == "" && b.PathRegex == "" {
+		return false
+	}
+
+	// b matches a single literal path
+	if b.PathRegex == "" && !config.IsPathTemplate(b.Path) {
+		switch {
+		case a.PathRegex != "":
+			pattern, err := config.CompilePathRegex(a.PathRegex)
+			return err == nil && pattern.MatchString(b.Path)
+		case config.IsPathTemplate(a.Path):
+			segments, err := config.ParsePathTemplate(a.Path)
+			if err != nil {
+				return false
+			}
+			_, ok := config.MatchPathSegments(segments, b.Path)
+			return ok
+		default:
+			return a.Path == b.Path
+		}
+	}
+
+	if b.PathRegex != "" {
+		return a.PathRegex == b.PathRegex
+	}
+
+	// b is a template, which a covers if it is a regex matching the same paths
+	// or a template at least as general segment by segment
+	if a.PathRegex != "" || !config.IsPathTemplate(a.Path) {
+		return false
+	}
+	aSegments, errA := config.ParsePathTemplate(a.Path)
+	bSegments, errB := config.ParsePathTemplate(b.Path)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	for i, segment := range aSegments {
+		if segment.CatchAll {
+			return i < len(bSegments)
+		}
+		if i >= len(bSegments) || bSegments[i].CatchAll {
+			return false
+		}
+		if segment.Param == "" && (bSegments[i].Param != "" || bSegments[i].Literal != segment.Literal) {
+			return false
+		}
+	}
+	return len(aSegments) == len(bSegments)
+}
+
+// criteriaKey encodes the matching criteria of a request so identical criteria share a key
+func criteriaKey(req config.RequestConfig) (string, error) {
+	req.Method = strings.ToUpper(req.Method)
+	key, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+// subsetOf reports whether every named matcher in a appears identically in b
+func subsetOf(a, b map[string]config.ValueMatcher) bool {
+	for name, matcher := range a {
+		other, exists := b[name]
+		if !exists || !jsonEqual(matcher, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// elementsOf reports whether every element of a appears identically in b
+func elementsOf[T any](a, b []T) bool {
+	for _, item := range a {
+		found := false
+		for _, other := range b {
+			if jsonEqual(item, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalHeaders keys header matchers by canonical header name
+func canonicalHeaders(headers map[string]config.ValueMatcher) map[string]config.ValueMatcher {
+	canonical := make(map[string]config.ValueMatcher, len(headers))
+	for name, matcher := range headers {
+		canonical[http.CanonicalHeaderKey(name)] = matcher
+	}
+	return canonical
+}
+
+// jsonEqual compares two configuration values by their JSON encoding
+func jsonEqual(a, b interface{}) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aJSON) == string(bJSON)
+}
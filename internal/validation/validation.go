@@ -1,11 +1,20 @@
 package validation
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"mime"
+	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"mock-harbor/internal/config"
+	"mock-harbor/internal/jsonpath"
+	"mock-harbor/internal/templating"
+	"mock-harbor/internal/xpath"
 )
 
 // ValidationError represents a configuration validation error
@@ -20,9 +29,11 @@ func (e ValidationError) Error() string {
 	return fmt.Sprintf("[%s] %s: %s", e.File, e.Field, e.Message)
 }
 
-// ValidationResult contains all validation errors
+// ValidationResult contains all validation errors and warnings
 type ValidationResult struct {
 	Errors []ValidationError
+	// Warnings point out likely mistakes that don't prevent the configuration from loading
+	Warnings []ValidationError
 }
 
 // IsValid returns true if there are no validation errors
@@ -123,15 +134,86 @@ func ValidateServiceConfig(cfg *config.ServiceConfig, filePath string) Validatio
 		})
 	}
 
+	// Validate virtual host names, which are matched without a port
+	hosts := make(map[string]bool)
+	for i, host := range cfg.Hosts {
+		field := fmt.Sprintf("hosts[%d]", i)
+		normalized := config.NormalizeHost(host)
+		switch {
+		case normalized == "":
+			result.Errors = append(result.Errors, ValidationError{
+				File:    fileName,
+				Field:   field,
+				Message: "host cannot be empty",
+			})
+		case strings.ContainsAny(normalized, ":/ "):
+			result.Errors = append(result.Errors, ValidationError{
+				File:    fileName,
+				Field:   field,
+				Message: fmt.Sprintf("invalid host '%s', must be a host name without scheme, port or path", host),
+			})
+		case hosts[normalized]:
+			result.Errors = append(result.Errors, ValidationError{
+				File:    fileName,
+				Field:   field,
+				Message: fmt.Sprintf("duplicate host '%s'", host),
+			})
+		}
+		hosts[normalized] = true
+	}
+
+	// Validate compression settings
+	if cfg.Compression != nil {
+		result.Errors = append(result.Errors, validateCompression(cfg.Compression, fileName, "compression")...)
+	}
+
 	return result
 }
 
-// ValidateMockConfigs validates a slice of mock configurations
-func ValidateMockConfigs(mocks []config.MockConfig, filePath string) ValidationResult {
+// validateCompression validates response compression settings
+func validateCompression(compression *config.CompressionConfig, fileName, fieldPrefix string) []ValidationError {
+	var errors []ValidationError
+
+	for i, encoding := range compression.Encodings {
+		switch strings.ToLower(encoding) {
+		case config.EncodingGzip, config.EncodingDeflate:
+		default:
+			message := fmt.Sprintf("unsupported encoding '%s', must be %s or %s", encoding, config.EncodingGzip, config.EncodingDeflate)
+			if strings.EqualFold(encoding, "br") {
+				// There is no Brotli encoder to compress bodies with on the fly
+				message += ", Brotli is only served from precompressed .br body files"
+			}
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fmt.Sprintf("%s.encodings[%d]", fieldPrefix, i),
+				Message: message,
+			})
+		}
+	}
+	if compression.MinSize < 0 {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   fieldPrefix + ".minSize",
+			Message: fmt.Sprintf("minSize must not be negative, got %d", compression.MinSize),
+		})
+	}
+	if strings.ContainsAny(compression.WrongEncoding, " ,;\r\n") {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   fieldPrefix + ".wrongEncoding",
+			Message: fmt.Sprintf("invalid encoding '%s', must be a single token", compression.WrongEncoding),
+		})
+	}
+	return errors
+}
+
+// ValidateMockConfigs validates a slice of mock configurations. A usecase with
+// a fallback.json, as reported by hasFallback, may have no mocks.
+func ValidateMockConfigs(mocks []config.MockConfig, filePath string, hasFallback bool) ValidationResult {
 	result := ValidationResult{}
 	fileName := filepath.Base(filePath)
 
-	if len(mocks) == 0 {
+	if len(mocks) == 0 && !hasFallback {
 		result.Errors = append(result.Errors, ValidationError{
 			File:    fileName,
 			Field:   "",
@@ -145,61 +227,721 @@ func ValidateMockConfigs(mocks []config.MockConfig, filePath string) ValidationR
 	for i, mock := range mocks {
 		mockPrefix := fmt.Sprintf("[%d]", i)
 
-		// Validate request path
-		if mock.Request.Path == "" {
+		// Validate request matching criteria
+		result.Errors = append(result.Errors, validateRequest(mock.Request, false, fileName, mockPrefix+".request")...)
+
+		// Check for duplicate endpoints (same method and matching criteria)
+		endpoint := mock.Request.Path
+		if mock.Request.PathRegex != "" {
+			endpoint = "regex:" + mock.Request.PathRegex
+		}
+		endpointKey, err := criteriaKey(mock.Request)
+		if err != nil {
 			result.Errors = append(result.Errors, ValidationError{
 				File:    fileName,
-				Field:   mockPrefix + ".request.path",
-				Message: "path cannot be empty",
+				Field:   mockPrefix + ".request",
+				Message: fmt.Sprintf("cannot compare request criteria: %v", err),
 			})
-		}
-
-		// Validate request method
-		if mock.Request.Method == "" {
+		} else if endpoints[endpointKey] {
 			result.Errors = append(result.Errors, ValidationError{
 				File:    fileName,
-				Field:   mockPrefix + ".request.method",
-				Message: "method cannot be empty",
+				Field:   mockPrefix + ".request",
+				Message: fmt.Sprintf("duplicate endpoint %s %s", mock.Request.Method, endpoint),
 			})
 		} else {
-			validMethods := map[string]bool{
-				"GET":     true,
-				"POST":    true,
-				"PUT":     true,
-				"DELETE":  true,
-				"PATCH":   true,
-				"HEAD":    true,
-				"OPTIONS": true,
-			}
-			if !validMethods[strings.ToUpper(mock.Request.Method)] {
+			endpoints[endpointKey] = true
+		}
+
+		if mock.Compression != nil {
+			result.Errors = append(result.Errors, validateCompression(mock.Compression, fileName, mockPrefix+".compression")...)
+		}
+
+		// Validate the response, the sequence of responses or the WebSocket conversation
+		switch {
+		case mock.WebSocket != nil:
+			result.Errors = append(result.Errors, validateWebSocketMock(mock, fileName, mockPrefix)...)
+		case mock.Responses != nil:
+			result.Errors = append(result.Errors, validateResponses(mock, fileName, mockPrefix)...)
+		default:
+			result.Errors = append(result.Errors, validateResponse(mock.Response, fileName, mockPrefix+".response")...)
+			result.Errors = append(result.Errors, checkUnweighted(mock.Response, fileName, mockPrefix+".response")...)
+			if mock.Mode != "" {
 				result.Errors = append(result.Errors, ValidationError{
 					File:    fileName,
-					Field:   mockPrefix + ".request.method",
-					Message: fmt.Sprintf("invalid HTTP method '%s'", mock.Request.Method),
+					Field:   mockPrefix + ".mode",
+					Message: "mode requires responses",
 				})
 			}
 		}
+	}
 
-		// Check for duplicate endpoints (same path + method)
-		endpointKey := strings.ToUpper(mock.Request.Method) + ":" + mock.Request.Path
-		if _, exists := endpoints[endpointKey]; exists {
-			result.Errors = append(result.Errors, ValidationError{
+	// Warn about mocks shadowed by mocks ranked ahead of them
+	result.Warnings = append(result.Warnings, checkUnreachableMocks(mocks, fileName)...)
+
+	return result
+}
+
+// validateRequest validates request matching criteria. Nested criteria of anyOf, allOf and
+// not may leave out path and method, as may top-level criteria that nest others.
+func validateRequest(req config.RequestConfig, nested bool, fileName, prefix string) []ValidationError {
+	var errors []ValidationError
+
+	// Validate request path, at most one of path and pathRegex may be set
+	// and one of them is required unless left to nested criteria
+	constrained := nested || req.HasCombinators()
+	if req.Path == "" && req.PathRegex == "" {
+		if !constrained {
+			errors = append(errors, ValidationError{
 				File:    fileName,
-				Field:   mockPrefix + ".request",
-				Message: fmt.Sprintf("duplicate endpoint %s %s", mock.Request.Method, mock.Request.Path),
+				Field:   prefix + ".path",
+				Message: "path cannot be empty",
 			})
 		}
-		endpoints[endpointKey] = true
+	} else if req.Path != "" && req.PathRegex != "" {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   prefix + ".pathRegex",
+			Message: "path and pathRegex cannot both be set",
+		})
+	} else if req.PathRegex != "" {
+		if _, err := config.CompilePathRegex(req.PathRegex); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   prefix + ".pathRegex",
+				Message: fmt.Sprintf("invalid path regex '%s': %v", req.PathRegex, err),
+			})
+		}
+	} else if config.IsPathTemplate(req.Path) {
+		if _, err := config.ParsePathTemplate(req.Path); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   prefix + ".path",
+				Message: fmt.Sprintf("invalid path template: %v", err),
+			})
+		}
+	}
 
-		// Validate response
-		if mock.Response.StatusCode < 100 || mock.Response.StatusCode > 599 {
-			result.Errors = append(result.Errors, ValidationError{
+	// Validate request method
+	if req.Method == "" {
+		if !constrained {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   prefix + ".method",
+				Message: "method cannot be empty",
+			})
+		}
+	} else {
+		validMethods := map[string]bool{
+			"GET":     true,
+			"POST":    true,
+			"PUT":     true,
+			"DELETE":  true,
+			"PATCH":   true,
+			"HEAD":    true,
+			"OPTIONS": true,
+		}
+		if !validMethods[strings.ToUpper(req.Method)] {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   prefix + ".method",
+				Message: fmt.Sprintf("invalid HTTP method '%s'", req.Method),
+			})
+		}
+	}
+
+	// Validate query string matchers
+	errors = append(errors, validateValueMatchers(req.Query, fileName, prefix+".query")...)
+
+	// Validate header and cookie matchers
+	errors = append(errors, validateValueMatchers(req.Headers, fileName, prefix+".headers")...)
+	errors = append(errors, validateValueMatchers(req.Cookies, fileName, prefix+".cookies")...)
+
+	// Validate the raw body regex
+	if req.BodyRegex != "" {
+		if _, err := regexp.Compile(req.BodyRegex); err != nil {
+			errors = append(errors, ValidationError{
 				File:    fileName,
-				Field:   mockPrefix + ".response.statusCode",
-				Message: fmt.Sprintf("invalid HTTP status code: %d", mock.Response.StatusCode),
+				Field:   prefix + ".bodyRegex",
+				Message: fmt.Sprintf("invalid body regex '%s': %v", req.BodyRegex, err),
 			})
 		}
 	}
 
+	// Validate the array match mode
+	switch req.ArrayMatch {
+	case "", config.ArrayMatchExact, config.ArrayMatchUnordered, config.ArrayMatchSubset:
+	default:
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   prefix + ".arrayMatch",
+			Message: fmt.Sprintf("invalid array match mode '%s', must be exact, unordered or subset", req.ArrayMatch),
+		})
+	}
+
+	// Validate JSONPath body matchers
+	for j, matcher := range req.BodyMatchers {
+		errors = append(errors, validateBodyMatcher(matcher, fileName, fmt.Sprintf("%s.bodyMatchers[%d]", prefix, j))...)
+	}
+
+	// Validate XPath body matchers
+	for j, matcher := range req.XPath {
+		errors = append(errors, validateXPathMatcher(matcher, req.Namespaces, fileName, fmt.Sprintf("%s.xpath[%d]", prefix, j))...)
+	}
+
+	// Validate form field and multipart matchers
+	errors = append(errors, validateValueMatchers(req.Form, fileName, prefix+".form")...)
+	for j, part := range req.Multipart {
+		partPrefix := fmt.Sprintf("%s.multipart[%d]", prefix, j)
+		if part.Name == "" {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   partPrefix + ".name",
+				Message: "part name cannot be empty",
+			})
+		}
+		if part.Filename != nil {
+			errors = append(errors, validateValueMatcher(*part.Filename, fileName, partPrefix+".filename")...)
+		}
+		if part.ContentType != nil {
+			errors = append(errors, validateValueMatcher(*part.ContentType, fileName, partPrefix+".contentType")...)
+		}
+		if part.Content != nil {
+			errors = append(errors, validateValueMatcher(*part.Content, fileName, partPrefix+".content")...)
+		}
+	}
+
+	// Validate nested criteria of the anyOf, allOf and not combinators
+	if req.AnyOf != nil && len(req.AnyOf) == 0 {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   prefix + ".anyOf",
+			Message: "anyOf must contain at least one request",
+		})
+	}
+	if req.AllOf != nil && len(req.AllOf) == 0 {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   prefix + ".allOf",
+			Message: "allOf must contain at least one request",
+		})
+	}
+	for j, nestedReq := range req.AnyOf {
+		errors = append(errors, validateNestedRequest(nestedReq, fileName, fmt.Sprintf("%s.anyOf[%d]", prefix, j))...)
+	}
+	for j, nestedReq := range req.AllOf {
+		errors = append(errors, validateNestedRequest(nestedReq, fileName, fmt.Sprintf("%s.allOf[%d]", prefix, j))...)
+	}
+	if req.Not != nil {
+		errors = append(errors, validateNestedRequest(*req.Not, fileName, prefix+".not")...)
+	}
+
+	return errors
+}
+
+// validateNestedRequest validates criteria nested in a combinator, which must not be empty
+func validateNestedRequest(req config.RequestConfig, fileName, prefix string) []ValidationError {
+	if encoded, err := json.Marshal(req); err == nil && string(encoded) == "{}" {
+		return []ValidationError{{
+			File:    fileName,
+			Field:   prefix,
+			Message: "nested request must specify at least one criterion",
+		}}
+	}
+	return validateRequest(req, true, fileName, prefix)
+}
+
+// ValidateFallbackConfig validates the fallback for unmatched requests of a service or usecase
+func ValidateFallbackConfig(fallback *config.FallbackConfig, filePath string) ValidationResult {
+	result := ValidationResult{}
+	fileName := filepath.Base(filePath)
+
+	if fallback.Usecase == "" && fallback.Response == nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    fileName,
+			Field:   "fallback",
+			Message: "fallback must specify a usecase, a response or both",
+		})
+	}
+
+	if fallback.Response != nil {
+		result.Errors = append(result.Errors, validateResponse(*fallback.Response, fileName, "fallback.response")...)
+		result.Errors = append(result.Errors, checkUnweighted(*fallback.Response, fileName, "fallback.response")...)
+	}
+
 	return result
 }
+
+// hasResponse reports whether any field of a mock's single response is set
+func hasResponse(response config.ResponseConfig) bool {
+	return response.StatusCode != 0 || response.Headers != nil || response.Body != nil || response.BodyXML != "" ||
+		response.BodyText != "" || response.BodyBase64 != "" || response.BodyFile != "" || response.Stream != nil ||
+		response.Seed != "" || response.Weight != 0 || len(response.Representations) > 0
+}
+
+// validateResponses validates a mock returning a sequence of responses
+func validateResponses(mock config.MockConfig, fileName, mockPrefix string) []ValidationError {
+	var errors []ValidationError
+
+	if len(mock.Responses) == 0 {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   mockPrefix + ".responses",
+			Message: "responses must not be empty",
+		})
+	}
+	if hasResponse(mock.Response) {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   mockPrefix,
+			Message: "only one of response and responses can be set",
+		})
+	}
+
+	switch mock.Mode {
+	case "", config.ModeSequential, config.ModeCycle, config.ModeRandom:
+	default:
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   mockPrefix + ".mode",
+			Message: fmt.Sprintf("invalid mode %q, must be one of %s, %s or %s", mock.Mode, config.ModeSequential, config.ModeCycle, config.ModeRandom),
+		})
+	}
+
+	for i, response := range mock.Responses {
+		responsePrefix := fmt.Sprintf("%s.responses[%d]", mockPrefix, i)
+		errors = append(errors, validateResponse(response, fileName, responsePrefix)...)
+
+		// Weights only apply to random picks
+		if response.Weight < 0 {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   responsePrefix + ".weight",
+				Message: fmt.Sprintf("weight must not be negative, got %d", response.Weight),
+			})
+		} else if response.Weight > 0 && mock.Mode != config.ModeRandom {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   responsePrefix + ".weight",
+				Message: fmt.Sprintf("weight requires mode %s", config.ModeRandom),
+			})
+		}
+	}
+	return errors
+}
+
+// validateStream validates a streamed response body
+func validateStream(stream *config.StreamConfig, fileName, fieldPrefix string) []ValidationError {
+	var errors []ValidationError
+	addError := func(field, message string) {
+		errors = append(errors, ValidationError{File: fileName, Field: fieldPrefix + field, Message: message})
+	}
+
+	if len(stream.Chunks) > 0 == (len(stream.Events) > 0) {
+		addError("", "exactly one of chunks and events must be set")
+	}
+	if stream.Repeat < -1 {
+		addError(".repeat", fmt.Sprintf("repeat must be -1 or more, got %d", stream.Repeat))
+	} else if stream.Repeat == -1 && len(stream.Chunks)+len(stream.Events) > 0 && !hasStreamDelay(stream) {
+		// Without a pause the stream would be written as fast as the client reads it
+		addError(".repeat", "a stream repeating forever needs a positive delay on at least one chunk or event")
+	}
+	if stream.KeepAlive < 0 {
+		addError(".keepAlive", fmt.Sprintf("keepAlive must not be negative, got %d", stream.KeepAlive))
+	} else if stream.KeepAlive > 0 && len(stream.Chunks) > 0 {
+		addError(".keepAlive", "keepAlive requires events")
+	}
+
+	for i, chunk := range stream.Chunks {
+		field := fmt.Sprintf(".chunks[%d]", i)
+		if chunk.Delay < 0 {
+			addError(field+".delay", fmt.Sprintf("delay must not be negative, got %d", chunk.Delay))
+		}
+		if templating.IsTemplate(chunk.Data) {
+			if _, err := templating.Parse("data", chunk.Data); err != nil {
+				addError(field+".data", fmt.Sprintf("invalid template: %v", err))
+			}
+		}
+	}
+
+	for i, event := range stream.Events {
+		field := fmt.Sprintf(".events[%d]", i)
+		if event.Delay < 0 {
+			addError(field+".delay", fmt.Sprintf("delay must not be negative, got %d", event.Delay))
+		}
+		if event.Retry < 0 {
+			addError(field+".retry", fmt.Sprintf("retry must not be negative, got %d", event.Retry))
+		}
+		// Line breaks would end the field early
+		if strings.ContainsAny(event.Event, "\r\n") {
+			addError(field+".event", "event must not contain line breaks")
+		}
+		if strings.ContainsAny(event.ID, "\r\n") {
+			addError(field+".id", "id must not contain line breaks")
+		}
+		if templating.IsTemplate(event.ID) {
+			if _, err := templating.Parse("id", event.ID); err != nil {
+				addError(field+".id", fmt.Sprintf("invalid template: %v", err))
+			}
+		}
+		if event.Data != nil {
+			if _, err := config.CompileTemplateValue("data", event.Data); err != nil {
+				addError(field+".data", fmt.Sprintf("invalid template: %v", err))
+			}
+		}
+	}
+	return errors
+}
+
+// hasStreamDelay reports whether any chunk or event of the stream is delayed
+func hasStreamDelay(stream *config.StreamConfig) bool {
+	for _, chunk := range stream.Chunks {
+		if chunk.Delay > 0 {
+			return true
+		}
+	}
+	for _, event := range stream.Events {
+		if event.Delay > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRepresentations validates the representations of a response and the media types they are keyed by
+func validateRepresentations(response config.ResponseConfig, fileName, fieldPrefix string) []ValidationError {
+	var errors []ValidationError
+
+	seen := make(map[string]string)
+	for _, mediaType := range response.MediaTypes() {
+		field := fmt.Sprintf("%s.representations[%s]", fieldPrefix, mediaType)
+		parsed, _, err := mime.ParseMediaType(mediaType)
+		if err != nil || strings.Count(parsed, "/") != 1 || strings.Contains(parsed, "*") {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   field,
+				Message: fmt.Sprintf("invalid media type '%s', expected a type such as application/json", mediaType),
+			})
+		} else if other, exists := seen[parsed]; exists {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   field,
+				Message: fmt.Sprintf("duplicate media type, also declared as '%s'", other),
+			})
+		} else {
+			seen[parsed] = mediaType
+		}
+
+		// Representations inherit the status code of the response
+		representation := response.Representations[mediaType]
+		if len(representation.Representations) > 0 {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   field,
+				Message: "representations can't be nested",
+			})
+		}
+		if representation.StatusCode == 0 {
+			representation.StatusCode = response.StatusCode
+		}
+		errors = append(errors, validateResponse(representation, fileName, field)...)
+		errors = append(errors, checkUnweighted(representation, fileName, field)...)
+	}
+	return errors
+}
+
+// checkUnweighted reports a weight set on a response other than an item of responses,
+// where it would be ignored
+func checkUnweighted(response config.ResponseConfig, fileName, fieldPrefix string) []ValidationError {
+	if response.Weight == 0 {
+		return nil
+	}
+	return []ValidationError{{
+		File:    fileName,
+		Field:   fieldPrefix + ".weight",
+		Message: fmt.Sprintf("weight only applies to items of responses with mode %s", config.ModeRandom),
+	}}
+}
+
+// validateResponse validates a mocked response
+func validateResponse(response config.ResponseConfig, fileName, fieldPrefix string) []ValidationError {
+	var errors []ValidationError
+
+	// At most one kind of body can be returned
+	bodies := 0
+	for _, set := range []bool{response.Body != nil, response.BodyXML != "", response.BodyText != "", response.BodyBase64 != "", response.BodyFile != "", response.Stream != nil} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   fieldPrefix,
+			Message: "only one of body, bodyXml, bodyText, bodyBase64, bodyFile and stream can be set",
+		})
+	}
+	if len(response.Representations) > 0 {
+		if bodies > 0 {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix,
+				Message: "representations replace the body, body, bodyXml, bodyText, bodyBase64, bodyFile and stream can't be set with them",
+			})
+		}
+		errors = append(errors, validateRepresentations(response, fileName, fieldPrefix)...)
+	}
+	if response.Stream != nil {
+		errors = append(errors, validateStream(response.Stream, fileName, fieldPrefix+".stream")...)
+	}
+	if templating.IsTemplate(response.BodyText) {
+		if _, err := templating.Parse("bodyText", response.BodyText); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyText",
+				Message: fmt.Sprintf("invalid template: %v", err),
+			})
+		}
+	}
+	if templating.IsTemplate(response.BodyFile) {
+		if _, err := templating.Parse("bodyFile", response.BodyFile); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyFile",
+				Message: fmt.Sprintf("invalid template: %v", err),
+			})
+		}
+	} else if response.BodyFilePath != "" {
+		// The loader resolves body files against the usecase directory, which they must stay inside
+		if _, inside := config.JoinBodyFile(response.BodyFileDir, response.BodyFile); !inside {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyFile",
+				Message: fmt.Sprintf("body file '%s' is outside the usecase directory", response.BodyFile),
+			})
+		} else if info, err := os.Stat(response.BodyFilePath); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyFile",
+				Message: fmt.Sprintf("body file '%s' not found", response.BodyFile),
+			})
+		} else if info.IsDir() {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyFile",
+				Message: fmt.Sprintf("body file '%s' is a directory", response.BodyFile),
+			})
+		}
+	}
+	if response.BodyBase64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(response.BodyBase64); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyBase64",
+				Message: fmt.Sprintf("invalid base64 content: %v", err),
+			})
+		}
+	}
+	if templating.IsTemplate(response.BodyXML) {
+		// Templated documents are only well-formed once rendered
+		if _, err := templating.Parse("bodyXml", response.BodyXML); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyXml",
+				Message: fmt.Sprintf("invalid template: %v", err),
+			})
+		}
+	} else if response.BodyXML != "" {
+		if _, err := xpath.Parse([]byte(response.BodyXML)); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".bodyXml",
+				Message: fmt.Sprintf("bodyXml is not well-formed XML: %v", err),
+			})
+		}
+	}
+	if response.Body != nil {
+		if _, err := config.CompileTemplateValue("body", response.Body); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".body",
+				Message: fmt.Sprintf("invalid template: %v", err),
+			})
+		}
+	}
+
+	if response.Seed != "" {
+		if _, err := templating.Parse("seed", response.Seed); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".seed",
+				Message: fmt.Sprintf("invalid template: %v", err),
+			})
+		}
+	}
+
+	// Validate templated header values, sorted so errors are reported in a stable order
+	headerNames := make([]string, 0, len(response.Headers))
+	for name := range response.Headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	for _, name := range headerNames {
+		if !templating.IsTemplate(response.Headers[name]) {
+			continue
+		}
+		if _, err := templating.Parse("headers."+name, response.Headers[name]); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   fieldPrefix + ".headers." + name,
+				Message: fmt.Sprintf("invalid template: %v", err),
+			})
+		}
+	}
+
+	if response.StatusCode < 100 || response.StatusCode > 599 {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   fieldPrefix + ".statusCode",
+			Message: fmt.Sprintf("invalid HTTP status code: %d", response.StatusCode),
+		})
+	}
+
+	return errors
+}
+
+// validateValueMatchers validates a map of named value matchers such as query parameters or headers
+func validateValueMatchers(matchers map[string]config.ValueMatcher, fileName, fieldPrefix string) []ValidationError {
+	var errors []ValidationError
+
+	// Sort names so errors are reported in a stable order
+	names := make([]string, 0, len(matchers))
+	for name := range matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := fieldPrefix + "." + name
+
+		if name == "" {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   field,
+				Message: "name cannot be empty",
+			})
+		}
+
+		errors = append(errors, validateValueMatcher(matchers[name], fileName, field)...)
+	}
+
+	return errors
+}
+
+// validateValueMatcher validates a single value matcher
+func validateValueMatcher(matcher config.ValueMatcher, fileName, field string) []ValidationError {
+	var errors []ValidationError
+
+	hasCriteria := matcher.Equals != nil || matcher.Contains != "" || matcher.Regex != "" || matcher.Present || matcher.Values != nil
+	if !hasCriteria && !matcher.Absent {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   field,
+			Message: "matcher must specify at least one of equals, contains, regex, present, absent or values",
+		})
+	}
+	if hasCriteria && matcher.Absent {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   field,
+			Message: "absent cannot be combined with other criteria",
+		})
+	}
+
+	if matcher.Regex != "" {
+		if _, err := regexp.Compile(matcher.Regex); err != nil {
+			errors = append(errors, ValidationError{
+				File:    fileName,
+				Field:   field + ".regex",
+				Message: fmt.Sprintf("invalid regex '%s': %v", matcher.Regex, err),
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateBodyMatcher validates a single JSONPath body matcher
+func validateBodyMatcher(matcher config.BodyMatcher, fileName, field string) []ValidationError {
+	var errors []ValidationError
+
+	if _, err := jsonpath.Parse(matcher.Path); err != nil {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   field + ".path",
+			Message: fmt.Sprintf("invalid JSONPath: %v", err),
+		})
+	}
+
+	return append(errors, validateOperator(matcher.Operator(), matcher.Value, fileName, field)...)
+}
+
+// validateXPathMatcher validates a single XPath body matcher
+func validateXPathMatcher(matcher config.XPathMatcher, namespaces map[string]string, fileName, field string) []ValidationError {
+	var errors []ValidationError
+
+	if _, err := xpath.Compile(matcher.Expr, namespaces); err != nil {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   field + ".expr",
+			Message: fmt.Sprintf("invalid XPath: %v", err),
+		})
+	}
+
+	return append(errors, validateOperator(matcher.Operator(), matcher.Value, fileName, field)...)
+}
+
+// validateOperator checks that a body matcher operator is known and has a suitable operand
+func validateOperator(op string, value interface{}, fileName, field string) []ValidationError {
+	var errors []ValidationError
+	addError := func(subField, message string) {
+		errors = append(errors, ValidationError{
+			File:    fileName,
+			Field:   field + subField,
+			Message: message,
+		})
+	}
+
+	switch op {
+	case config.OpEquals, config.OpNotEquals:
+		// Any value is allowed, a missing value compares with null
+	case config.OpExists, config.OpAbsent:
+		if value != nil {
+			addError(".value", fmt.Sprintf("operator '%s' does not take a value", op))
+		}
+	case config.OpContains:
+		if value == nil {
+			addError(".value", "operator 'contains' requires a value")
+		}
+	case config.OpRegex:
+		expr, ok := value.(string)
+		if !ok {
+			addError(".value", "operator 'regex' requires a string pattern")
+		} else if _, err := regexp.Compile(expr); err != nil {
+			addError(".value", fmt.Sprintf("invalid regex '%s': %v", expr, err))
+		}
+	case config.OpGt, config.OpLt:
+		switch value.(type) {
+		case float64, string:
+		default:
+			addError(".value", fmt.Sprintf("operator '%s' requires a number or string", op))
+		}
+	case config.OpIn:
+		if _, ok := value.([]interface{}); !ok {
+			addError(".value", "operator 'in' requires an array of candidates")
+		}
+	default:
+		addError(".op", fmt.Sprintf("unknown operator '%s'", op))
+	}
+
+	return errors
+}
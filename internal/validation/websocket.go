@@ -0,0 +1,137 @@
+package validation
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"mock-harbor/internal/config"
+	"mock-harbor/internal/templating"
+)
+
+// validateWebSocketMock validates a mock upgrading requests to a scripted WebSocket conversation
+func validateWebSocketMock(mock config.MockConfig, fileName, mockPrefix string) []ValidationError {
+	var errors []ValidationError
+	addError := func(field, message string) {
+		errors = append(errors, ValidationError{File: fileName, Field: mockPrefix + field, Message: message})
+	}
+
+	if hasResponse(mock.Response) || mock.Responses != nil {
+		addError("", "only one of response, responses and websocket can be set")
+	}
+	if mock.Mode != "" {
+		addError(".mode", "mode requires responses")
+	}
+	if mock.Request.Method != "" && !strings.EqualFold(mock.Request.Method, http.MethodGet) {
+		addError(".request.method", fmt.Sprintf("WebSocket connections are opened with GET, not %s", mock.Request.Method))
+	}
+
+	ws := mock.WebSocket
+	prefix := ".websocket"
+	if len(ws.OnOpen) == 0 && len(ws.Timers) == 0 && len(ws.Rules) == 0 && ws.Close == nil {
+		addError(prefix, "at least one of onOpen, timers, rules and close must be set")
+	}
+	if strings.ContainsAny(ws.Protocol, " ,") {
+		addError(prefix+".protocol", fmt.Sprintf("protocol must be a single token, got '%s'", ws.Protocol))
+	}
+
+	for i, message := range ws.OnOpen {
+		errors = append(errors, validateWebSocketMessage(message, fileName, fmt.Sprintf("%s%s.onOpen[%d]", mockPrefix, prefix, i))...)
+	}
+
+	for i, timer := range ws.Timers {
+		field := fmt.Sprintf("%s.timers[%d]", prefix, i)
+		if timer.Interval <= 0 {
+			addError(field+".interval", fmt.Sprintf("interval must be positive, got %d", timer.Interval))
+		}
+		if timer.Count < 0 {
+			addError(field+".count", fmt.Sprintf("count must not be negative, got %d", timer.Count))
+		}
+		errors = append(errors, validateWebSocketMessage(timer.Message, fileName, mockPrefix+field+".message")...)
+	}
+
+	for i, rule := range ws.Rules {
+		field := fmt.Sprintf("%s.rules[%d]", prefix, i)
+		if rule.Regex != "" {
+			if _, err := regexp.Compile(rule.Regex); err != nil {
+				addError(field+".regex", fmt.Sprintf("invalid regular expression: %v", err))
+			}
+		}
+		if len(rule.Reply) == 0 && rule.Close == nil {
+			addError(field, "at least one of reply and close must be set")
+		}
+		for j, message := range rule.Reply {
+			errors = append(errors, validateWebSocketMessage(message, fileName, fmt.Sprintf("%s%s.reply[%d]", mockPrefix, field, j))...)
+		}
+		if rule.Close != nil {
+			errors = append(errors, validateWebSocketClose(*rule.Close, fileName, mockPrefix+field+".close")...)
+		}
+	}
+
+	if ws.Close != nil {
+		errors = append(errors, validateWebSocketClose(*ws.Close, fileName, mockPrefix+prefix+".close")...)
+	}
+	return errors
+}
+
+// validateWebSocketMessage validates a message sent to WebSocket clients
+func validateWebSocketMessage(message config.WebSocketMessage, fileName, fieldPrefix string) []ValidationError {
+	var errors []ValidationError
+	addError := func(field, message string) {
+		errors = append(errors, ValidationError{File: fileName, Field: fieldPrefix + field, Message: message})
+	}
+
+	kinds := 0
+	for _, set := range []bool{message.Text != "", message.JSON != nil, message.Binary != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		addError("", "exactly one of text, json and binary must be set")
+	}
+	if message.Delay < 0 {
+		addError(".delay", fmt.Sprintf("delay must not be negative, got %d", message.Delay))
+	}
+
+	if templating.IsTemplate(message.Text) {
+		if _, err := templating.Parse("text", message.Text); err != nil {
+			addError(".text", fmt.Sprintf("invalid template: %v", err))
+		}
+	}
+	if message.JSON != nil {
+		if _, err := config.CompileTemplateValue("json", message.JSON); err != nil {
+			addError(".json", fmt.Sprintf("invalid template: %v", err))
+		}
+	}
+	if message.Binary != "" {
+		if _, err := base64.StdEncoding.DecodeString(message.Binary); err != nil {
+			addError(".binary", fmt.Sprintf("invalid base64 content: %v", err))
+		}
+	}
+	return errors
+}
+
+// validateWebSocketClose validates the close code and reason sent when closing a WebSocket connection
+func validateWebSocketClose(closeConfig config.WebSocketClose, fileName, fieldPrefix string) []ValidationError {
+	var errors []ValidationError
+	addError := func(field, message string) {
+		errors = append(errors, ValidationError{File: fileName, Field: fieldPrefix + field, Message: message})
+	}
+
+	if closeConfig.Delay < 0 {
+		addError(".delay", fmt.Sprintf("delay must not be negative, got %d", closeConfig.Delay))
+	}
+
+	// Only the codes defined for close frames, and those reserved for libraries and applications, can be sent
+	code := closeConfig.Code
+	if code != 0 && !(code >= 1000 && code <= 1003) && !(code >= 1007 && code <= 1014) && !(code >= 3000 && code <= 4999) {
+		addError(".code", fmt.Sprintf("invalid close code: %d", code))
+	}
+	if len(closeConfig.Reason) > 123 {
+		addError(".reason", "reason must be at most 123 bytes long")
+	}
+	return errors
+}
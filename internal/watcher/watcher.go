@@ -94,8 +94,8 @@ func (cw *ConfigWatcher) processEvents() {
 				return
 			}
 
-			// Skip temporary files and directories
-			if strings.HasPrefix(filepath.Base(event.Name), ".") {
+			// Skip hidden files and directories, and the temporary files editors save
+			if isTemporaryFile(event.Name) {
 				continue
 			}
 
@@ -115,9 +115,11 @@ func (cw *ConfigWatcher) processEvents() {
 				continue
 			}
 
-			// Only process if it's a YAML or JSON file
+			// Only process YAML and JSON files, and any file in a usecase directory
+			// since responses may load their body from it. Changes to usecases the
+			// service doesn't load are ignored by the callback.
 			ext := strings.ToLower(filepath.Ext(event.Name))
-			if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			if ext != ".yaml" && ext != ".yml" && ext != ".json" && !cw.isUsecaseFile(event.Name) {
 				continue
 			}
 
@@ -215,8 +217,8 @@ func (cw *ConfigWatcher) classifyConfigFile(relPath string) (serviceID string, c
 			return serviceID, "service"
 		}
 		
-		// Mock configs
-		if len(parts) >= 4 && parts[1] == "usecases" && strings.HasSuffix(parts[len(parts)-1], ".json") {
+		// Mock configs and the body files they load
+		if len(parts) >= 4 && parts[1] == "usecases" {
 			return serviceID, "mock"
 		}
 	}
@@ -225,6 +227,28 @@ func (cw *ConfigWatcher) classifyConfigFile(relPath string) (serviceID string, c
 	return serviceID, "unknown"
 }
 
+// isUsecaseFile reports whether the path is inside a usecase directory of a service
+func (cw *ConfigWatcher) isUsecaseFile(path string) bool {
+	relPath, err := filepath.Rel(cw.configRoot, path)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(relPath, string(filepath.Separator))
+	return len(parts) >= 4 && parts[1] == "usecases"
+}
+
+// isTemporaryFile reports whether the path is a hidden file, or a backup, swap or
+// autosave file written by an editor, none of which are configuration
+func isTemporaryFile(path string) bool {
+	name := filepath.Base(path)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".swp", ".swo", ".swx", ".tmp":
+		return true
+	}
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") ||
+		(strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#"))
+}
+
 // addRecursive adds a directory and all its subdirectories to the watcher
 func (cw *ConfigWatcher) addRecursive(root string) error {
 	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
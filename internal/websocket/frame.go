@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Frame opcodes
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// maxMessageSize limits the size of the messages read from clients
+const maxMessageSize = 16 << 20
+
+// errTooBig reports a message larger than maxMessageSize
+var errTooBig = errors.New("message too big")
+
+// frame is a single decoded WebSocket frame
+type frame struct {
+	final   bool
+	opcode  int
+	payload []byte
+}
+
+// readFrame reads a frame sent by a client, whose payload must be masked and at most limit bytes
+func readFrame(r io.Reader, limit int) (frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{final: header[0]&0x80 != 0, opcode: int(header[0] & 0x0F)}
+	if header[0]&0x70 != 0 {
+		return frame{}, errors.New("reserved bits set without a negotiated extension")
+	}
+	if header[1]&0x80 == 0 {
+		return frame{}, errors.New("client frame is not masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if f.opcode >= OpClose && (length > 125 || !f.final) {
+		return frame{}, fmt.Errorf("invalid control frame with opcode %d", f.opcode)
+	}
+	if length > uint64(limit) {
+		return frame{}, errTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return frame{}, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return frame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// writeFrame writes a final, unmasked frame as sent by a server
+func writeFrame(w io.Writer, opcode int, payload []byte) error {
+	header := []byte{0x80 | byte(opcode), 0}
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	_, err := w.Write(append(header, payload...))
+	return err
+}
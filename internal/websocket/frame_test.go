@@ -0,0 +1,326 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clientFrame encodes a frame the way a client sends it, masked with key
+func clientFrame(final bool, opcode int, payload []byte, key [4]byte) []byte {
+	first := byte(opcode)
+	if final {
+		first |= 0x80
+	}
+	header := []byte{first, 0x80}
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] |= byte(length)
+	case length <= 0xFFFF:
+		header[1] |= 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] |= 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ key[i%4]
+	}
+	return append(append(header, key[:]...), masked...)
+}
+
+// readServerFrame decodes an unmasked frame as sent by writeFrame
+func readServerFrame(r io.Reader) (frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+	if header[1]&0x80 != 0 {
+		return frame{}, errors.New("server frame is masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	f := frame{final: header[0]&0x80 != 0, opcode: int(header[0] & 0x0F), payload: make([]byte, length)}
+	_, err := io.ReadFull(r, f.payload)
+	return f, err
+}
+
+var testKey = [4]byte{0x37, 0xfa, 0x21, 0x3d}
+
+func TestReadFrameUnmasksClientFrames(t *testing.T) {
+	tests := []struct {
+		name    string
+		final   bool
+		opcode  int
+		payload []byte
+	}{
+		{"empty text", true, OpText, nil},
+		{"short text", true, OpText, []byte("Hello")},
+		{"largest 7-bit length", true, OpBinary, bytes.Repeat([]byte{0xAB}, 125)},
+		{"smallest 16-bit length", true, OpBinary, bytes.Repeat([]byte{0xCD}, 126)},
+		{"largest 16-bit length", false, OpText, bytes.Repeat([]byte("x"), 0xFFFF)},
+		{"64-bit length", true, OpBinary, bytes.Repeat([]byte{0x01, 0x02, 0x03}, 30000)},
+		{"continuation", false, OpContinuation, []byte("more")},
+		{"ping", true, OpPing, []byte("are you there")},
+		{"close", true, OpClose, []byte{0x03, 0xE8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := readFrame(bytes.NewReader(clientFrame(tt.final, tt.opcode, tt.payload, testKey)), maxMessageSize)
+			if err != nil {
+				t.Fatalf("readFrame failed: %v", err)
+			}
+			if f.final != tt.final || f.opcode != tt.opcode || !bytes.Equal(f.payload, tt.payload) {
+				t.Errorf("readFrame = {final: %v, opcode: %d, %d bytes}, want {final: %v, opcode: %d, %d bytes}",
+					f.final, f.opcode, len(f.payload), tt.final, tt.opcode, len(tt.payload))
+			}
+		})
+	}
+}
+
+func TestReadFrameRejectsInvalidFrames(t *testing.T) {
+	unmasked := clientFrame(true, OpText, []byte("hi"), testKey)
+	unmasked[1] &^= 0x80
+
+	reserved := clientFrame(true, OpText, []byte("hi"), testKey)
+	reserved[0] |= 0x40
+
+	tests := []struct {
+		name  string
+		data  []byte
+		limit int
+		err   error
+	}{
+		{"unmasked", unmasked, maxMessageSize, nil},
+		{"reserved bits", reserved, maxMessageSize, nil},
+		{"long control frame", clientFrame(true, OpPing, make([]byte, 126), testKey), maxMessageSize, nil},
+		{"fragmented control frame", clientFrame(false, OpClose, nil, testKey), maxMessageSize, nil},
+		{"above limit", clientFrame(true, OpBinary, make([]byte, 11), testKey), 10, errTooBig},
+		{"truncated header", []byte{0x81}, maxMessageSize, io.ErrUnexpectedEOF},
+		{"truncated payload", clientFrame(true, OpText, []byte("hello"), testKey)[:8], maxMessageSize, io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readFrame(bytes.NewReader(tt.data), tt.limit)
+			if err == nil {
+				t.Fatal("readFrame succeeded, want an error")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("readFrame error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWriteFrameRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 125, 126, 0xFFFF, 0x10000} {
+		payload := bytes.Repeat([]byte{byte(size)}, size)
+		var buf bytes.Buffer
+		if err := writeFrame(&buf, OpBinary, payload); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := readServerFrame(&buf)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !f.final || f.opcode != OpBinary || !bytes.Equal(f.payload, payload) {
+			t.Errorf("size %d: got {final: %v, opcode: %d, %d bytes}", size, f.final, f.opcode, len(f.payload))
+		}
+		if buf.Len() != 0 {
+			t.Errorf("size %d: %d bytes left after the frame", size, buf.Len())
+		}
+	}
+}
+
+// testConn connects a server Conn to the client end of a pipe
+func testConn(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return &Conn{conn: server, reader: bufio.NewReader(server)}, client
+}
+
+type readResult struct {
+	opcode  int
+	message []byte
+	err     error
+}
+
+// readAsync reads a message from the server side of the connection in the background
+func readAsync(c *Conn) <-chan readResult {
+	results := make(chan readResult, 1)
+	go func() {
+		opcode, message, err := c.ReadMessage()
+		results <- readResult{opcode, message, err}
+	}()
+	return results
+}
+
+func TestReadMessageJoinsFragmentsAndAnswersPings(t *testing.T) {
+	conn, client := testConn(t)
+	results := readAsync(conn)
+
+	go func() {
+		client.Write(clientFrame(false, OpText, []byte("Hel"), testKey))
+		client.Write(clientFrame(true, OpPing, []byte("ping-1"), testKey))
+		client.Write(clientFrame(false, OpContinuation, []byte("lo, "), testKey))
+		client.Write(clientFrame(true, OpPong, []byte("unsolicited"), testKey))
+		client.Write(clientFrame(true, OpContinuation, []byte("world"), testKey))
+	}()
+
+	// The ping is answered with a pong echoing its payload while the message is read
+	pong, err := readServerFrame(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pong.opcode != OpPong || string(pong.payload) != "ping-1" {
+		t.Errorf("got opcode %d with %q, want a pong with %q", pong.opcode, pong.payload, "ping-1")
+	}
+
+	result := <-results
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if result.opcode != OpText || string(result.message) != "Hello, world" {
+		t.Errorf("ReadMessage = %d %q, want a text message %q", result.opcode, result.message, "Hello, world")
+	}
+}
+
+func TestReadMessageAcknowledgesClose(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     []byte
+		want        CloseError
+		wantPayload []byte
+	}{
+		{"code and reason", []byte{0x03, 0xE9, 'b', 'y', 'e'}, CloseError{Code: CloseGoingAway, Reason: "bye"}, []byte{0x03, 0xE9}},
+		{"code", []byte{0x03, 0xE8}, CloseError{Code: CloseNormal}, []byte{0x03, 0xE8}},
+		// A close without a status is acknowledged without one, as 1005 is never sent
+		{"no status", nil, CloseError{Code: CloseNoStatus}, []byte{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := testConn(t)
+			results := readAsync(conn)
+			go client.Write(clientFrame(true, OpClose, tt.payload, testKey))
+
+			ack, err := readServerFrame(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ack.opcode != OpClose || !bytes.Equal(ack.payload, tt.wantPayload) {
+				t.Errorf("acknowledged with opcode %d and % x, want a close frame with % x", ack.opcode, ack.payload, tt.wantPayload)
+			}
+
+			var closeErr *CloseError
+			if result := <-results; !errors.As(result.err, &closeErr) || *closeErr != tt.want {
+				t.Errorf("ReadMessage error = %v, want %v", result.err, &tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMessageRejectsProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"continuation without a message", [][]byte{clientFrame(true, OpContinuation, []byte("x"), testKey)}},
+		{"new message before the last ended", [][]byte{
+			clientFrame(false, OpText, []byte("a"), testKey),
+			clientFrame(true, OpBinary, []byte("b"), testKey),
+		}},
+		{"unknown opcode", [][]byte{clientFrame(true, 0x3, nil, testKey)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := testConn(t)
+			results := readAsync(conn)
+			go func() {
+				for _, f := range tt.frames {
+					client.Write(f)
+				}
+			}()
+
+			closing, err := readServerFrame(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if closing.opcode != OpClose || len(closing.payload) < 2 || binary.BigEndian.Uint16(closing.payload) != CloseProtocolError {
+				t.Errorf("got opcode %d with % x, want a close frame with code %d", closing.opcode, closing.payload, CloseProtocolError)
+			}
+			if result := <-results; result.err == nil {
+				t.Error("ReadMessage succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCloseEncodesCodeAndReason(t *testing.T) {
+	conn, client := testConn(t)
+	go conn.Close(CloseNormal, "done")
+
+	f, err := readServerFrame(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x03, 0xE8, 'd', 'o', 'n', 'e'}; f.opcode != OpClose || !bytes.Equal(f.payload, want) {
+		t.Errorf("got opcode %d with % x, want a close frame with % x", f.opcode, f.payload, want)
+	}
+	if err := conn.WriteMessage(OpText, []byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("WriteMessage after Close = %v, want %v", err, net.ErrClosed)
+	}
+	if err := conn.Close(CloseNormal, ""); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+}
+
+func TestParseClose(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		want    CloseError
+	}{
+		{nil, CloseError{Code: CloseNoStatus}},
+		{[]byte{0x03}, CloseError{Code: CloseNoStatus}},
+		{[]byte{0x03, 0xF1}, CloseError{Code: CloseTooBig}},
+		{[]byte{0x0F, 0xA0, 'o', 'k'}, CloseError{Code: 4000, Reason: "ok"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseClose(tt.payload); !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("parseClose(% x) = %+v, want %+v", tt.payload, *got, tt.want)
+		}
+	}
+}
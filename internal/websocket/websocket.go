@@ -0,0 +1,220 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptGUID is appended to the client's key to compute the handshake's accept value
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// writeTimeout limits how long sending a frame may take, so a client that stops
+// reading can't hold up other writers and Close
+const writeTimeout = 10 * time.Second
+
+// Close codes of the close frames sent and received
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseNoStatus      = 1005
+	CloseAbnormal      = 1006
+	CloseTooBig        = 1009
+)
+
+// ErrNotUpgrade reports a request that doesn't ask for a WebSocket connection
+var ErrNotUpgrade = errors.New("request is not a WebSocket upgrade")
+
+// CloseError reports that the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("connection closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("connection closed with code %d: %s", e.Code, e.Reason)
+}
+
+// IsUpgrade reports whether the request asks to upgrade the connection to a WebSocket
+func IsUpgrade(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		headerContains(r.Header, "Connection", "upgrade") &&
+		headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the opening handshake and takes over the request's connection.
+// The protocol is accepted if the client offers it, and ignored otherwise.
+func Upgrade(w http.ResponseWriter, r *http.Request, protocol string) (*Conn, error) {
+	if !IsUpgrade(r) {
+		return nil, ErrNotUpgrade
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("%w: missing key or unsupported version '%s'", ErrNotUpgrade, r.Header.Get("Sec-WebSocket-Version"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("response writer can't hijack the connection")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	sb.WriteString("Upgrade: websocket\r\n")
+	sb.WriteString("Connection: Upgrade\r\n")
+	sb.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if protocol != "" && headerContains(r.Header, "Sec-WebSocket-Protocol", protocol) {
+		sb.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	sb.WriteString("\r\n")
+	if _, err := rw.WriteString(sb.String()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: netConn, reader: rw.Reader}, nil
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a client's key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether a comma-separated header lists the token, ignoring case
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Conn is the server side of a WebSocket connection. Messages may be written
+// concurrently with each other and with reading.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	mutex  sync.Mutex // Serializes writes
+	closed bool       // Whether a close frame was sent
+}
+
+// ReadMessage reads the next text or binary message, joining fragmented frames.
+// Pings are answered while reading, and a close frame from the client is
+// acknowledged and returned as a *CloseError.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var opcode int
+	var message []byte
+	for {
+		f, err := readFrame(c.reader, maxMessageSize-len(message))
+		if err != nil {
+			if errors.Is(err, errTooBig) {
+				c.Close(CloseTooBig, "message too big")
+			}
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case OpPing:
+			if err := c.writeFrame(OpPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			closeErr := parseClose(f.payload)
+			c.Close(closeErr.Code, "")
+			return 0, nil, closeErr
+		case OpContinuation:
+			if opcode == 0 {
+				c.Close(CloseProtocolError, "unexpected continuation frame")
+				return 0, nil, errors.New("unexpected continuation frame")
+			}
+		case OpText, OpBinary:
+			if opcode != 0 {
+				c.Close(CloseProtocolError, "expected continuation frame")
+				return 0, nil, errors.New("expected continuation frame")
+			}
+			opcode = f.opcode
+		default:
+			c.Close(CloseProtocolError, "unknown opcode")
+			return 0, nil, fmt.Errorf("unknown opcode %d", f.opcode)
+		}
+
+		message = append(message, f.payload...)
+		if f.final {
+			return opcode, message, nil
+		}
+	}
+}
+
+// WriteMessage sends a text or binary message in a single frame
+func (c *Conn) WriteMessage(opcode int, payload []byte) error {
+	return c.writeFrame(opcode, payload)
+}
+
+// Close sends a close frame with the code and reason, unless one was sent
+// already, and closes the connection
+func (c *Conn) Close(code int, reason string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	// Codes reserved for reporting a missing status or abnormal closure are never sent
+	var payload []byte
+	if code != CloseNoStatus && code != CloseAbnormal {
+		payload = append([]byte{byte(code >> 8), byte(code)}, reason...)
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	writeFrame(c.conn, OpClose, payload)
+	return c.conn.Close()
+}
+
+// writeFrame sends a single final frame, failing once the connection is closed
+// or if the client doesn't take it within the write timeout
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return writeFrame(c.conn, opcode, payload)
+}
+
+// parseClose decodes the code and reason of a close frame
+func parseClose(payload []byte) *CloseError {
+	if len(payload) < 2 {
+		return &CloseError{Code: CloseNoStatus}
+	}
+	return &CloseError{Code: int(payload[0])<<8 | int(payload[1]), Reason: string(payload[2:])}
+}
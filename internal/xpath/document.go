@@ -0,0 +1,265 @@
+package xpath
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// nodeKind identifies the type of a document node
+type nodeKind int
+
+const (
+	documentNode nodeKind = iota
+	elementNode
+	attributeNode
+	textNode
+)
+
+// node is an element, attribute or text node of a parsed XML document
+type node struct {
+	kind     nodeKind
+	space    string
+	local    string
+	value    string
+	parent   *node
+	attrs    []*node
+	children []*node
+}
+
+// Document is a parsed XML document that expressions can be evaluated against
+type Document struct {
+	root *node
+}
+
+// Parse reads an XML document
+func Parse(data []byte) (*Document, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	root := &node{kind: documentNode}
+	current := root
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := &node{kind: elementNode, space: t.Name.Space, local: t.Name.Local, parent: current}
+			for _, attr := range t.Attr {
+				// Namespace declarations are not attributes in the XPath data model
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				element.attrs = append(element.attrs, &node{
+					kind:   attributeNode,
+					space:  attr.Name.Space,
+					local:  attr.Name.Local,
+					value:  attr.Value,
+					parent: element,
+				})
+			}
+			current.children = append(current.children, element)
+			current = element
+		case xml.EndElement:
+			current = current.parent
+		case xml.CharData:
+			current.children = append(current.children, &node{kind: textNode, value: string(t), parent: current})
+		}
+	}
+
+	return &Document{root: root}, nil
+}
+
+// stringValue returns the XPath string-value of a node
+func (n *node) stringValue() string {
+	switch n.kind {
+	case attributeNode, textNode:
+		return n.value
+	}
+
+	var sb strings.Builder
+	var walk func(*node)
+	walk = func(current *node) {
+		for _, child := range current.children {
+			if child.kind == textNode {
+				sb.WriteString(child.value)
+			} else {
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// Select evaluates the expression against the document and returns the string values
+// of the selected nodes, with surrounding whitespace trimmed
+func (e *Expr) Select(doc *Document) []string {
+	nodes := e.evaluate(doc.root, doc.root)
+	values := make([]string, len(nodes))
+	for i, n := range nodes {
+		values[i] = strings.TrimSpace(n.stringValue())
+	}
+	return values
+}
+
+// evaluate applies the expression's steps starting from the context node
+func (e *Expr) evaluate(context, root *node) []*node {
+	current := []*node{context}
+	if e.absolute {
+		current = []*node{root}
+	}
+
+	for _, s := range e.steps {
+		var next []*node
+		seen := make(map[*node]bool)
+		for _, n := range current {
+			for _, selected := range s.apply(n, root) {
+				if !seen[selected] {
+					seen[selected] = true
+					next = append(next, selected)
+				}
+			}
+		}
+		current = next
+	}
+
+	return current
+}
+
+// apply selects the nodes matched by a single step from one context node
+func (s step) apply(context, root *node) []*node {
+	var candidates []*node
+
+	switch s.axis {
+	case axisChild:
+		candidates = context.children
+	case axisDescendant:
+		var walk func(*node)
+		walk = func(current *node) {
+			for _, child := range current.children {
+				candidates = append(candidates, child)
+				walk(child)
+			}
+		}
+		walk(context)
+	case axisAttribute:
+		candidates = context.attrs
+	case axisSelf:
+		candidates = []*node{context}
+	case axisParent:
+		if context.parent != nil {
+			candidates = []*node{context.parent}
+		}
+	}
+
+	var matched []*node
+	for _, candidate := range candidates {
+		if s.test(candidate) {
+			matched = append(matched, candidate)
+		}
+	}
+
+	// // abbreviates /descendant-or-self::node()/, so predicates filter the
+	// children of each parent separately, as //item[1] selects every first item
+	if s.axis == axisDescendant && len(s.predicates) > 0 {
+		return s.filterByParent(matched, root)
+	}
+	for _, pred := range s.predicates {
+		matched = pred.filter(matched, root)
+	}
+	return matched
+}
+
+// filterByParent applies the step's predicates to the nodes sharing each parent,
+// keeping the selected nodes in document order
+func (s step) filterByParent(nodes []*node, root *node) []*node {
+	var parents []*node
+	siblings := make(map[*node][]*node)
+	for _, n := range nodes {
+		if _, exists := siblings[n.parent]; !exists {
+			parents = append(parents, n.parent)
+		}
+		siblings[n.parent] = append(siblings[n.parent], n)
+	}
+
+	kept := make(map[*node]bool)
+	for _, parent := range parents {
+		group := siblings[parent]
+		for _, pred := range s.predicates {
+			group = pred.filter(group, root)
+		}
+		for _, n := range group {
+			kept[n] = true
+		}
+	}
+
+	var filtered []*node
+	for _, n := range nodes {
+		if kept[n] {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered
+}
+
+// test checks a candidate node against the step's name test
+func (s step) test(n *node) bool {
+	if s.local == "text()" {
+		return n.kind == textNode
+	}
+
+	switch s.axis {
+	case axisAttribute:
+		if n.kind != attributeNode {
+			return false
+		}
+	case axisSelf, axisParent:
+		return true
+	default:
+		if n.kind != elementNode {
+			return false
+		}
+	}
+
+	if s.space != "" && n.space != s.space {
+		return false
+	}
+	return s.local == "*" || n.local == s.local
+}
+
+// filter keeps the nodes that satisfy the predicate
+func (p predicate) filter(nodes []*node, root *node) []*node {
+	if p.position > 0 {
+		if p.position <= len(nodes) {
+			return []*node{nodes[p.position-1]}
+		}
+		return nil
+	}
+
+	var kept []*node
+	for _, n := range nodes {
+		selected := p.path.evaluate(n, root)
+		if p.op == "" {
+			if len(selected) > 0 {
+				kept = append(kept, n)
+			}
+			continue
+		}
+
+		for _, s := range selected {
+			value := strings.TrimSpace(s.stringValue())
+			if (p.op == "=") == (value == p.literal) {
+				kept = append(kept, n)
+				break
+			}
+		}
+	}
+	return kept
+}
@@ -0,0 +1,286 @@
+package xpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// axis identifies how a step moves from its context nodes
+type axis int
+
+const (
+	axisChild axis = iota
+	axisDescendant
+	axisAttribute
+	axisSelf
+	axisParent
+)
+
+// step is a single location step such as //soap:Body or @id
+type step struct {
+	axis axis
+	// space is the namespace URI required by a prefixed name test
+	space string
+	// local is the local name, * for any name or text() for text nodes
+	local      string
+	predicates []predicate
+}
+
+// predicate filters the nodes selected by a step
+type predicate struct {
+	// position selects the nth node (1-based), when non-zero
+	position int
+	// path is evaluated relative to each node, which passes if it selects anything
+	// or, when op is set, if any selected value compares with literal
+	path    *Expr
+	op      string
+	literal string
+}
+
+// Expr is a compiled XPath expression supporting a practical subset of XPath 1.0:
+// absolute and relative location paths, // descendants, *, @attr, text(), . and ..,
+// and predicates of the form [n], [path], [path='literal'] and [path!='literal'].
+//
+// Prefixed names are resolved through the namespace map given to Compile, while
+// unprefixed names match elements by local name in any namespace.
+type Expr struct {
+	source   string
+	absolute bool
+	steps    []step
+}
+
+// String returns the original expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Compile parses an XPath expression, resolving prefixes through namespaces
+func Compile(source string, namespaces map[string]string) (*Expr, error) {
+	p := &parser{input: strings.TrimSpace(source), namespaces: namespaces}
+	expr, err := p.parsePath()
+	if err != nil {
+		return nil, fmt.Errorf("%v in '%s'", err, source)
+	}
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected '%s' in '%s'", p.input[p.pos:], source)
+	}
+	expr.source = source
+	return expr, nil
+}
+
+// parser holds the state of a single expression parse
+type parser struct {
+	input      string
+	pos        int
+	namespaces map[string]string
+}
+
+// peek reports whether the remaining input starts with s
+func (p *parser) peek(s string) bool {
+	return strings.HasPrefix(p.input[p.pos:], s)
+}
+
+// skipSpace advances past whitespace
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// parsePath parses a location path up to the end of input, a ']' or a comparison
+func (p *parser) parsePath() (*Expr, error) {
+	expr := &Expr{}
+	p.skipSpace()
+
+	nextAxis := axisChild
+	switch {
+	case p.peek("//"):
+		expr.absolute = true
+		nextAxis = axisDescendant
+		p.pos += 2
+	case p.peek("/"):
+		expr.absolute = true
+		p.pos++
+		// A lone / selects the document itself
+		if p.pos >= len(p.input) || p.peek("]") {
+			return expr, nil
+		}
+	}
+
+	for {
+		s, err := p.parseStep(nextAxis)
+		if err != nil {
+			return nil, err
+		}
+		expr.steps = append(expr.steps, s)
+
+		switch {
+		case p.peek("//"):
+			nextAxis = axisDescendant
+			p.pos += 2
+		case p.peek("/"):
+			nextAxis = axisChild
+			p.pos++
+		default:
+			return expr, nil
+		}
+	}
+}
+
+// parseStep parses a single location step and its predicates
+func (p *parser) parseStep(stepAxis axis) (step, error) {
+	s := step{axis: stepAxis}
+
+	switch {
+	case p.peek(".."):
+		p.pos += 2
+		s.axis = axisParent
+		s.local = "*"
+		return s, nil
+	case p.peek("."):
+		p.pos++
+		if s.axis == axisDescendant {
+			s.local = "*"
+			return s, nil
+		}
+		s.axis = axisSelf
+		s.local = "*"
+		return s, nil
+	case p.peek("@"):
+		p.pos++
+		if s.axis == axisDescendant {
+			return s, fmt.Errorf("attributes cannot follow '//'")
+		}
+		s.axis = axisAttribute
+	}
+
+	name := p.readName()
+	if name == "" {
+		return s, fmt.Errorf("expected a name at position %d", p.pos)
+	}
+
+	if name == "text" && p.peek("()") {
+		if s.axis == axisAttribute {
+			return s, fmt.Errorf("text() cannot be an attribute")
+		}
+		p.pos += 2
+		s.local = "text()"
+	} else {
+		space, local, err := p.resolveName(name)
+		if err != nil {
+			return s, err
+		}
+		s.space, s.local = space, local
+	}
+
+	for p.peek("[") {
+		p.pos++
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return s, err
+		}
+		s.predicates = append(s.predicates, pred)
+	}
+
+	return s, nil
+}
+
+// readName reads a possibly prefixed name or *
+func (p *parser) readName() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '*' || c == ':' || c == '-' || c == '_' || c == '.' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80 {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// resolveName splits a name into namespace URI and local name
+func (p *parser) resolveName(name string) (string, string, error) {
+	prefix, local, prefixed := strings.Cut(name, ":")
+	if !prefixed {
+		return "", name, nil
+	}
+	if prefix == "" || local == "" {
+		return "", "", fmt.Errorf("invalid name '%s'", name)
+	}
+
+	space, ok := p.namespaces[prefix]
+	if !ok {
+		return "", "", fmt.Errorf("undeclared namespace prefix '%s'", prefix)
+	}
+	return space, local, nil
+}
+
+// parsePredicate parses the contents of a [...] predicate including the closing bracket
+func (p *parser) parsePredicate() (predicate, error) {
+	var pred predicate
+	p.skipSpace()
+
+	// Positional predicate
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos > start {
+		position, _ := strconv.Atoi(p.input[start:p.pos])
+		if position < 1 {
+			return pred, fmt.Errorf("positions start at 1")
+		}
+		pred.position = position
+	} else {
+		path, err := p.parsePath()
+		if err != nil {
+			return pred, err
+		}
+		pred.path = path
+
+		p.skipSpace()
+		switch {
+		case p.peek("!="):
+			pred.op = "!="
+			p.pos += 2
+		case p.peek("="):
+			pred.op = "="
+			p.pos++
+		}
+		if pred.op != "" {
+			literal, err := p.readLiteral()
+			if err != nil {
+				return pred, err
+			}
+			pred.literal = literal
+		}
+	}
+
+	p.skipSpace()
+	if !p.peek("]") {
+		return pred, fmt.Errorf("expected ']' at position %d", p.pos)
+	}
+	p.pos++
+	return pred, nil
+}
+
+// readLiteral reads a single or double quoted string
+func (p *parser) readLiteral() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) || (p.input[p.pos] != '\'' && p.input[p.pos] != '"') {
+		return "", fmt.Errorf("expected a quoted string at position %d", p.pos)
+	}
+
+	quote := p.input[p.pos]
+	end := strings.IndexByte(p.input[p.pos+1:], quote)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string at position %d", p.pos)
+	}
+
+	literal := p.input[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return literal, nil
+}
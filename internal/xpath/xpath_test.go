@@ -0,0 +1,144 @@
+package xpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testDocument = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:meta">
+  <soap:Header>
+    <m:trace id="t-1">abc</m:trace>
+  </soap:Header>
+  <soap:Body>
+    <order xmlns="urn:orders" id="42" m:channel="web">
+      <customer type="vip">Ana <!-- comment --><b>Silva</b></customer>
+      <items>
+        <item sku="A-1"><qty>2</qty></item>
+        <item sku="B-2"><qty>5</qty></item>
+      </items>
+      <items>
+        <item sku="C-3"><qty>1</qty></item>
+      </items>
+      <m:note>  fragile  </m:note>
+    </order>
+  </soap:Body>
+</soap:Envelope>`
+
+var testNamespaces = map[string]string{
+	"soap": "http://schemas.xmlsoap.org/soap/envelope/",
+	"o":    "urn:orders",
+	"meta": "urn:meta",
+	"x":    "urn:unused",
+}
+
+func TestSelect(t *testing.T) {
+	doc, err := Parse([]byte(testDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		// Prefixed names resolve through the namespace map, whatever prefix the document uses
+		{"/soap:Envelope/soap:Body/o:order/@id", []string{"42"}},
+		{"//meta:trace", []string{"abc"}},
+		{"//meta:trace/@id", []string{"t-1"}},
+		{"//x:order", nil},
+		{"//soap:order", nil},
+
+		// Unprefixed names match by local name in any namespace, including the default one
+		{"/Envelope/Body/order/customer/@type", []string{"vip"}},
+		{"//order/@id", []string{"42"}},
+		{"//trace", []string{"abc"}},
+		{"//o:order/o:customer/b", []string{"Silva"}},
+
+		// Attributes, prefixed attributes and wildcards
+		{"//order/@meta:channel", []string{"web"}},
+		{"//order/@x:channel", nil},
+		{"//order/@channel", []string{"web"}},
+		{"//item/@sku", []string{"A-1", "B-2", "C-3"}},
+		{"//order/@*", []string{"42", "web"}},
+		{"//soap:Body/*/@id", []string{"42"}},
+
+		// Text and string values
+		{"//customer/text()", []string{"Ana"}},
+		{"//customer", []string{"Ana Silva"}},
+		{"//meta:note", []string{"fragile"}},
+		{"//qty/text()", []string{"2", "5", "1"}},
+
+		// Positional predicates apply to each parent's children under //
+		{"//item[1]/@sku", []string{"A-1", "C-3"}},
+		{"//items[2]/item/@sku", []string{"C-3"}},
+		{"//items/item[2]/@sku", []string{"B-2"}},
+		{"//item[3]", nil},
+
+		// Path and comparison predicates
+		{"//item[@sku='B-2']/qty", []string{"5"}},
+		{`//item[@sku="B-2"]/qty`, []string{"5"}},
+		{"//item[@sku!='B-2']/qty", []string{"2", "1"}},
+		{"//item[qty='1']/@sku", []string{"C-3"}},
+		{"//item[qty]", []string{"2", "5", "1"}},
+		{"//item[missing]", nil},
+		{"//customer[@type='vip'][b='Silva']/@type", []string{"vip"}},
+		{"//customer[@type='regular']", nil},
+		{"//order[items/item/@sku='C-3']/@id", []string{"42"}},
+		{"//item[ @sku = 'A-1' ]/qty", []string{"2"}},
+
+		// Self and parent steps
+		{"//qty/../@sku", []string{"A-1", "B-2", "C-3"}},
+		{"//customer/.", []string{"Ana Silva"}},
+		{"//item[.='5']/@sku", []string{"B-2"}},
+
+		// Relative paths start at the document
+		{"Envelope/Header/trace/@id", []string{"t-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr, testNamespaces)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expr, err)
+			}
+			got := expr.Select(doc)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"//",
+		"/a/",
+		"//@id",
+		"@text()",
+		"undeclared:order",
+		":order",
+		"o:",
+		"//item[0]",
+		"//item[@sku='A-1'",
+		"//item[@sku='A-1]",
+		"//item[@sku=A-1]",
+		"//item]",
+	} {
+		if _, err := Compile(expr, testNamespaces); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestParseRejectsMalformedDocuments(t *testing.T) {
+	for _, data := range []string{"<a>", "<a></b>", "<a><b></a>"} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", data)
+		}
+	}
+}